@@ -14,8 +14,22 @@ import (
 	"github.com/cenkalti/backoff"
 )
 
+const (
+	bungieBaseURL = "https://www.bungie.net"
+)
+
 type Client struct {
 	AuthConfig *oauth2.Config
+
+	// BaseURL overrides the Bungie base URL, e.g. for testing.
+	BaseURL string
+}
+
+func (c *Client) baseURL() string {
+	if c.BaseURL != "" {
+		return c.BaseURL
+	}
+	return bungieBaseURL
 }
 
 func (c *Client) GetCurrentBungieAccount(tok *oauth2.Token) *GetCurrentBungieAccountResponse {
@@ -25,8 +39,10 @@ func (c *Client) GetCurrentBungieAccount(tok *oauth2.Token) *GetCurrentBungieAcc
 	return resp
 }
 
-func (c *Client) MyCharacterVendorData(tok *oauth2.Token, membershipType db.DestinyMembershipType, characterID db.DestinyCharacterID, vendorHash uint32) *MyCharacterVendorDataResponse {
-	req := &MyCharacterVendorDataRequest{int64(membershipType), string(characterID), vendorHash}
+// MyCharacterVendorData returns the strings in the response (e.g. category
+// titles) in locale, or in English if locale is "".
+func (c *Client) MyCharacterVendorData(tok *oauth2.Token, membershipType db.DestinyMembershipType, characterID db.DestinyCharacterID, vendorHash uint32, locale string) *MyCharacterVendorDataResponse {
+	req := &MyCharacterVendorDataRequest{int64(membershipType), string(characterID), vendorHash, locale}
 	resp := new(MyCharacterVendorDataResponse)
 	c.get(tok, req, resp)
 	return resp
@@ -39,15 +55,38 @@ func (c *Client) GetAllVendorsForCurrentCharacter(tok *oauth2.Token, membershipT
 	return resp
 }
 
+// GetManifest doesn't require the user to be authenticated, and returns an
+// error instead of panicking since it's used by command line tools rather than
+// by handlers.
+func (c *Client) GetManifest() (*GetManifestResponse, error) {
+	req := &GetManifestRequest{}
+	resp := new(GetManifestResponse)
+	if err := c.tryGet(nil, req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// get is like tryGet, but panics on errors.
 func (c *Client) get(tok *oauth2.Token, req Request, resp Response) {
-	httpReq, err := http.NewRequest("GET", req.URL(), nil)
-	if err != nil {
+	if err := c.tryGet(tok, req, resp); err != nil {
 		panic(err)
 	}
+}
+
+func (c *Client) tryGet(tok *oauth2.Token, req Request, resp Response) error {
+	url := c.baseURL() + req.Path()
+	httpReq, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
 	httpReq.Header.Add("X-API-Key", c.AuthConfig.ClientID)
 
-	client := c.AuthConfig.Client(context.TODO(), tok)
-	err = backoff.RetryNotify(
+	client := http.DefaultClient
+	if tok != nil {
+		client = c.AuthConfig.Client(context.TODO(), tok)
+	}
+	return backoff.RetryNotify(
 		func() error {
 			httpResp, err := client.Do(httpReq)
 			if err != nil {
@@ -61,7 +100,7 @@ func (c *Client) get(tok *oauth2.Token, req Request, resp Response) {
 				return err
 			}
 			if resp.GetHeader().ErrorCode != 1 {
-				return fmt.Errorf("bad message for %v: %+v", req.URL(), resp)
+				return fmt.Errorf("bad message for %v: %+v", url, resp)
 			}
 			return nil
 		},
@@ -70,7 +109,4 @@ func (c *Client) get(tok *oauth2.Token, req Request, resp Response) {
 			log.Printf("retrying: %v", err)
 		},
 	)
-	if err != nil {
-		panic(err)
-	}
 }
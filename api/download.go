@@ -0,0 +1,127 @@
+package api
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// UpdateManifest downloads the manifest database for locale into the
+// directory containing link, verifies that it can be opened by NewManifest,
+// and then atomically points the symlink at link to it.  It returns the path
+// to the new database.
+func (c *Client) UpdateManifest(locale, link string) (string, error) {
+	manifestResp, err := c.GetManifest()
+	if err != nil {
+		return "", fmt.Errorf("failed to get manifest: %v", err)
+	}
+	contentPath, ok := manifestResp.Response.MobileWorldContentPaths[locale]
+	if !ok {
+		return "", fmt.Errorf("no manifest for locale %q", locale)
+	}
+	log.Printf("manifest version %v for %v is at %v", manifestResp.Response.Version, locale, contentPath)
+
+	dir := filepath.Dir(link)
+	path, err := c.downloadManifest(contentPath, dir)
+	if err != nil {
+		return "", err
+	}
+
+	// Make sure that the database is usable before switching to it.
+	m, err := NewManifest(path)
+	if err != nil {
+		os.Remove(path)
+		return "", fmt.Errorf("downloaded manifest is invalid: %v", err)
+	}
+	m.Close()
+
+	// Create the new symlink next to the old one and rename it over the old
+	// one, so that the link always points to a valid database.
+	tmpLink := link + ".tmp"
+	os.Remove(tmpLink)
+	if err := os.Symlink(filepath.Base(path), tmpLink); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmpLink, link); err != nil {
+		os.Remove(tmpLink)
+		return "", err
+	}
+	return path, nil
+}
+
+// downloadManifest downloads the zipped database at contentPath and extracts
+// it into dir.
+func (c *Client) downloadManifest(contentPath, dir string) (string, error) {
+	tf, err := ioutil.TempFile(dir, "manifest-*.zip")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tf.Name())
+	defer tf.Close()
+
+	// Download the zipped database.
+	req, err := http.NewRequest("GET", c.baseURL()+contentPath, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Add("X-API-Key", c.AuthConfig.ClientID)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("bad response downloading %v: %v", contentPath, resp.StatusCode)
+	}
+	size, err := io.Copy(tf, resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	// Unzip the database.
+	zr, err := zip.NewReader(tf, size)
+	if err != nil {
+		return "", err
+	}
+	if len(zr.File) != 1 {
+		return "", fmt.Errorf("expected one entry in manifest zip, got %v", len(zr.File))
+	}
+	return extract(zr.File[0], dir)
+}
+
+func extract(f *zip.File, dir string) (string, error) {
+	// Don't trust the name in the zip to stay inside dir.
+	path := filepath.Join(dir, filepath.Base(f.Name))
+
+	r, err := f.Open()
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	// Write to a temporary file first so that a partially written database is
+	// never left at path.
+	w, err := ioutil.TempFile(dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		w.Close()
+		os.Remove(w.Name())
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		os.Remove(w.Name())
+		return "", err
+	}
+	if err := os.Rename(w.Name(), path); err != nil {
+		os.Remove(w.Name())
+		return "", err
+	}
+	return path, nil
+}
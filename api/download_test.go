@@ -0,0 +1,162 @@
+package api
+
+import (
+	"archive/zip"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/zhirsch/oauth2"
+)
+
+// manifestTables are the tables of a manifest database that are used.
+var manifestTables = []string{"DestinyVendorDefinition", "DestinyInventoryItemDefinition", "DestinyRewardSourceDefinition"}
+
+// writeManifestDB creates a manifest database at path with the tables, and
+// the definitions in rows, by table and hash.
+func writeManifestDB(t *testing.T, path string, tables []string, rows map[string]map[uint32]string) {
+	sqldb, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqldb.Close()
+	for _, table := range tables {
+		if _, err := sqldb.Exec("CREATE TABLE " + table + "(id INTEGER PRIMARY KEY, json TEXT);"); err != nil {
+			t.Fatal(err)
+		}
+		for hash, value := range rows[table] {
+			if _, err := sqldb.Exec("INSERT INTO "+table+"(id, json) VALUES(?, ?);", HashToID(hash), value); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+// newManifestZip returns a zip containing a manifest database named name,
+// with empty definition tables.
+func newManifestZip(t *testing.T, name string) []byte {
+	dir, err := ioutil.TempDir("", "manifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, name)
+	writeManifestDB(t, path, manifestTables, nil)
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := new(bytes.Buffer)
+	zw := zip.NewWriter(buf)
+	w, err := zw.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// newFakeBungie returns a server that serves a manifest for "en" whose
+// database is in the zip at contentPath.
+func newFakeBungie(t *testing.T, contentPath string, zipped []byte) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/Platform/Destiny/Manifest/", func(w http.ResponseWriter, r *http.Request) {
+		resp := new(GetManifestResponse)
+		resp.ErrorCode = 1
+		resp.Response.Version = "1"
+		resp.Response.MobileWorldContentPaths = map[string]string{"en": contentPath}
+		json.NewEncoder(w).Encode(resp)
+	})
+	mux.HandleFunc(contentPath, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-API-Key") != "key" {
+			http.Error(w, "no API key", http.StatusForbidden)
+			return
+		}
+		w.Write(zipped)
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestUpdateManifest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "updatemanifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	link := filepath.Join(dir, "manifest.db")
+
+	for _, name := range []string{"world_sql_content_1.content", "world_sql_content_2.content"} {
+		zipped := newManifestZip(t, name)
+		server := newFakeBungie(t, "/common/destiny_content/sqlite/en/"+name, zipped)
+		client := &Client{
+			AuthConfig: &oauth2.Config{ClientID: "key"},
+			BaseURL:    server.URL,
+		}
+		path, err := client.UpdateManifest("en", link)
+		server.Close()
+		if err != nil {
+			t.Fatalf("UpdateManifest for %v: %v", name, err)
+		}
+
+		if want := filepath.Join(dir, name); path != want {
+			t.Errorf("got path %v, want %v", path, want)
+		}
+		if target, err := os.Readlink(link); err != nil {
+			t.Fatal(err)
+		} else if target != name {
+			t.Errorf("link points to %v, want %v", target, name)
+		}
+		m, err := NewManifest(link)
+		if err != nil {
+			t.Fatalf("can't open the manifest through the link: %v", err)
+		}
+		if m.Version() != name {
+			t.Errorf("got version %v, want %v", m.Version(), name)
+		}
+		m.Close()
+	}
+
+	// Only the databases and the link are left behind.
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 3 {
+		var names []string
+		for _, f := range files {
+			names = append(names, f.Name())
+		}
+		t.Errorf("got files %v, want the two databases and the link", names)
+	}
+}
+
+func TestUpdateManifestUnknownLocale(t *testing.T) {
+	dir, err := ioutil.TempDir("", "updatemanifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	server := newFakeBungie(t, "/en.content", nil)
+	defer server.Close()
+	client := &Client{
+		AuthConfig: &oauth2.Config{ClientID: "key"},
+		BaseURL:    server.URL,
+	}
+	if _, err := client.UpdateManifest("xx", filepath.Join(dir, "manifest.db")); err == nil {
+		t.Error("UpdateManifest succeeded for a locale without a manifest")
+	}
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+)
+
+// HashToID converts a hash to the id that it's stored under in the manifest
+// database.  The ids are the hashes reinterpreted as signed 32-bit integers.
+func HashToID(hash uint32) int32 {
+	return int32(hash)
+}
+
+// IDToHash is the inverse of HashToID.
+func IDToHash(id int32) uint32 {
+	return uint32(id)
+}
+
+// A RawDefinition is the undecoded JSON of a definition.
+type RawDefinition struct {
+	Hash uint32
+	JSON []byte
+}
+
+// Tables returns the names of the tables that can be queried.
+func (m *Manifest) Tables() []string {
+	mdb := m.acquire()
+	defer mdb.inFlight.Done()
+
+	var tables []string
+	for table := range mdb.stmts {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	return tables
+}
+
+// GetRawDefinition returns the JSON of the definition in table, bypassing the
+// cache.
+func (m *Manifest) GetRawDefinition(table string, hash uint32) (*RawDefinition, error) {
+	mdb := m.acquire()
+	defer mdb.inFlight.Done()
+
+	stmt, ok := mdb.stmts[table]
+	if !ok {
+		return nil, fmt.Errorf("unknown table %v", table)
+	}
+	var value string
+	if err := stmt.QueryRow(HashToID(hash)).Scan(&value); err == sql.ErrNoRows {
+		return nil, &NotFoundError{table, hash, mdb.version}
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to select %v %v: %v", table, hash, err)
+	}
+	return &RawDefinition{Hash: hash, JSON: []byte(value)}, nil
+}
+
+// SearchRawDefinitions returns the definitions in table whose JSON contains
+// s, ignoring case.  It scans the whole table, so it's meant for debugging.
+func (m *Manifest) SearchRawDefinitions(table, s string) ([]*RawDefinition, error) {
+	mdb := m.acquire()
+	defer mdb.inFlight.Done()
+
+	// Only allow the known tables, since the name can't be a parameter.
+	if _, ok := mdb.stmts[table]; !ok {
+		return nil, fmt.Errorf("unknown table %v", table)
+	}
+	rows, err := mdb.db.Query(fmt.Sprintf("SELECT id, json FROM %v WHERE json LIKE '%%' || ? || '%%';", table), s)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var definitions []*RawDefinition
+	for rows.Next() {
+		var id int32
+		var value string
+		if err := rows.Scan(&id, &value); err != nil {
+			return nil, err
+		}
+		definitions = append(definitions, &RawDefinition{Hash: IDToHash(id), JSON: []byte(value)})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return definitions, nil
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Manifests are the manifest databases for each locale that the server
+// supports.
+type Manifests struct {
+	defaultLocale string
+	manifests     map[string]*Manifest
+}
+
+// NewManifests opens the manifest database for each locale in paths, which
+// maps locales (e.g. "en" or "pt-br") to database paths.  paths must include
+// defaultLocale.
+func NewManifests(defaultLocale string, paths map[string]string, opts ManifestOptions) (*Manifests, error) {
+	if _, ok := paths[defaultLocale]; !ok {
+		return nil, fmt.Errorf("no manifest for default locale %q", defaultLocale)
+	}
+	ms := &Manifests{
+		defaultLocale: defaultLocale,
+		manifests:     make(map[string]*Manifest),
+	}
+	for locale, path := range paths {
+		m, err := NewManifestWithOptions(path, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open manifest for %q: %v", locale, err)
+		}
+		m.locale = locale
+		ms.manifests[locale] = m
+	}
+	return ms, nil
+}
+
+// ParseLocalePaths parses a comma-separated list of locale=path pairs, e.g.
+// "fr=/manifests/fr.db,de=/manifests/de.db".
+func ParseLocalePaths(s string) (map[string]string, error) {
+	paths := make(map[string]string)
+	if s == "" {
+		return paths, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			return nil, fmt.Errorf("bad locale=path pair %q", pair)
+		}
+		paths[strings.ToLower(kv[0])] = kv[1]
+	}
+	return paths, nil
+}
+
+// Get returns the manifest for locale, or the manifest for the default locale
+// if there isn't one for locale.
+func (ms *Manifests) Get(locale string) *Manifest {
+	if m, ok := ms.manifests[locale]; ok {
+		return m
+	}
+	return ms.manifests[ms.defaultLocale]
+}
+
+// Locales returns the supported locales, in sorted order.
+func (ms *Manifests) Locales() []string {
+	var locales []string
+	for locale := range ms.manifests {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// Match returns the first of the language tags (e.g. "fr-CA") that is
+// supported, either exactly or by its primary language, or the default locale
+// if none are supported.
+func (ms *Manifests) Match(tags ...string) string {
+	for _, tag := range tags {
+		tag = strings.ToLower(tag)
+		if _, ok := ms.manifests[tag]; ok {
+			return tag
+		}
+		if i := strings.Index(tag, "-"); i > 0 {
+			if _, ok := ms.manifests[tag[:i]]; ok {
+				return tag[:i]
+			}
+		}
+	}
+	return ms.defaultLocale
+}
+
+// Reload calls Reload on each manifest.
+func (ms *Manifests) Reload() {
+	for locale, m := range ms.manifests {
+		if _, err := m.Reload(); err != nil {
+			log.Printf("failed to reload manifest for %q: %v", locale, err)
+		}
+	}
+}
+
+// Watch calls Reload every interval.  It never returns.
+func (ms *Manifests) Watch(interval time.Duration) {
+	for range time.Tick(interval) {
+		ms.Reload()
+	}
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"container/list"
+	"sync"
+)
+
+type cacheKey struct {
+	version string
+	table   string
+	hash    uint32
+}
+
+type cacheEntry struct {
+	key   cacheKey
+	value interface{}
+}
+
+// CacheStats are the counters for the Manifest's definition cache.
+type CacheStats struct {
+	Hits   uint64
+	Misses uint64
+	Len    int
+}
+
+// lruCache is a bounded cache of decoded definitions that evicts the least
+// recently used definition when it's full.
+type lruCache struct {
+	size int
+
+	mu      sync.Mutex
+	ll      *list.List
+	entries map[cacheKey]*list.Element
+	hits    uint64
+	misses  uint64
+}
+
+func newLRUCache(size int) *lruCache {
+	return &lruCache{
+		size:    size,
+		ll:      list.New(),
+		entries: make(map[cacheKey]*list.Element),
+	}
+}
+
+func (c *lruCache) get(key cacheKey) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok {
+		c.hits++
+		c.ll.MoveToFront(e)
+		return e.Value.(*cacheEntry).value, true
+	}
+	c.misses++
+	return nil, false
+}
+
+func (c *lruCache) add(key cacheKey, value interface{}) {
+	if c.size <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok {
+		c.ll.MoveToFront(e)
+		e.Value.(*cacheEntry).value = value
+		return
+	}
+	c.entries[key] = c.ll.PushFront(&cacheEntry{key, value})
+	for c.ll.Len() > c.size {
+		e := c.ll.Back()
+		c.ll.Remove(e)
+		delete(c.entries, e.Value.(*cacheEntry).key)
+	}
+}
+
+func (c *lruCache) stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return CacheStats{
+		Hits:   c.hits,
+		Misses: c.misses,
+		Len:    c.ll.Len(),
+	}
+}
@@ -5,55 +5,290 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
+// A Manifest provides the definitions in the Destiny manifest database.  The
+// database can be replaced while the Manifest is in use by pointing path (which
+// is usually a symlink) at a new database and calling Reload.
+//
+// Decoded definitions are cached, so the definitions returned by the Get
+// methods are shared and must not be modified.
 type Manifest struct {
-	db    *sql.DB
-	stmts map[string]*sql.Stmt
+	path   string
+	locale string
+	cache  *lruCache
+	opts   ManifestOptions
+
+	mu      sync.RWMutex
+	current *manifestDB
+}
+
+const defaultCacheSize = 16384
+
+type ManifestOptions struct {
+	// CacheSize is the number of decoded definitions to cache.  If zero,
+	// defaultCacheSize is used.  If negative, definitions aren't cached.
+	CacheSize int
+
+	// WarmUpVendors are the vendors whose definitions, and the definitions
+	// of the items that they sell, are loaded into the cache when a database
+	// is opened.
+	WarmUpVendors []uint32
+}
+
+// manifestDB is a single version of the manifest database.
+type manifestDB struct {
+	db      *sql.DB
+	stmts   map[string]*sql.Stmt
+	version string
+	info    os.FileInfo
+
+	// inFlight counts the queries using the database, so that it isn't closed
+	// out from under them after it's replaced.
+	inFlight sync.WaitGroup
 }
 
 func NewManifest(path string) (*Manifest, error) {
-	m := &Manifest{stmts: make(map[string]*sql.Stmt)}
+	return NewManifestWithOptions(path, ManifestOptions{})
+}
+
+func NewManifestWithOptions(path string, opts ManifestOptions) (*Manifest, error) {
+	cacheSize := opts.CacheSize
+	if cacheSize == 0 {
+		cacheSize = defaultCacheSize
+	}
+	m := &Manifest{
+		path:  path,
+		cache: newLRUCache(cacheSize),
+		opts:  opts,
+	}
+
+	mdb, err := openManifestDB(path)
+	if err != nil {
+		return nil, err
+	}
+	m.warmUp(mdb)
+	m.current = mdb
+
+	return m, nil
+}
+
+func openManifestDB(path string) (*manifestDB, error) {
+	// Resolve the path so that the database stays the same even if the
+	// symlink is changed, since database/sql may open new connections at any
+	// time.
+	realPath, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve manifest db: %v", err)
+	}
+	info, err := os.Stat(realPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat manifest db: %v", err)
+	}
+	mdb := &manifestDB{
+		stmts:   make(map[string]*sql.Stmt),
+		version: filepath.Base(realPath),
+		info:    info,
+	}
 
 	// Open the database connection.
-	if sqldb, err := sql.Open("sqlite3", path); err != nil {
+	if sqldb, err := sql.Open("sqlite3", realPath); err != nil {
 		return nil, fmt.Errorf("failed to open manifest db: %v", err)
 	} else if sqldb == nil {
 		return nil, fmt.Errorf("manifest db is nil")
 	} else {
-		m.db = sqldb
+		mdb.db = sqldb
 	}
 
 	// Prepare the SELECT queries.
-	if err := m.prepare("DestinyVendorDefinition"); err != nil {
+	if err := mdb.prepare("DestinyVendorDefinition"); err != nil {
+		mdb.close()
 		return nil, err
 	}
-	if err := m.prepare("DestinyInventoryItemDefinition"); err != nil {
+	if err := mdb.prepare("DestinyInventoryItemDefinition"); err != nil {
+		mdb.close()
 		return nil, err
 	}
+	// Older manifests don't have the reward sources, which only means that
+	// items don't have sources.
+	if err := mdb.prepare("DestinyRewardSourceDefinition"); err != nil {
+		log.Printf("manifest %v has no reward sources: %v", mdb.version, err)
+	}
 
-	return m, nil
+	return mdb, nil
 }
 
-func (m *Manifest) prepare(table string) error {
-	stmt, err := m.db.Prepare(fmt.Sprintf("SELECT json FROM %v WHERE id = ?;", table))
+func (mdb *manifestDB) prepare(table string) error {
+	stmt, err := mdb.db.Prepare(fmt.Sprintf("SELECT json FROM %v WHERE id = ?;", table))
 	if err != nil {
 		return err
 	}
-	m.stmts[table] = stmt
+	mdb.stmts[table] = stmt
 	return nil
 }
 
-func (m *Manifest) get(table string, hash uint32, definition interface{}) {
+func (mdb *manifestDB) close() error {
+	for _, stmt := range mdb.stmts {
+		stmt.Close()
+	}
+	return mdb.db.Close()
+}
+
+// Locale returns the locale of the manifest, or "" if it wasn't opened by
+// NewManifests.
+func (m *Manifest) Locale() string {
+	return m.locale
+}
+
+// Version identifies the database that is currently in use.  It's the name of
+// the database file, which Bungie changes for every version of the manifest.
+func (m *Manifest) Version() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.current.version
+}
+
+// Reload switches to the database at the Manifest's path if it has changed.
+// The old database is closed once the queries using it have finished.
+func (m *Manifest) Reload() (bool, error) {
+	m.mu.RLock()
+	old := m.current
+	m.mu.RUnlock()
+	if info, err := os.Stat(m.path); err != nil {
+		return false, fmt.Errorf("failed to stat manifest db: %v", err)
+	} else if os.SameFile(info, old.info) && info.ModTime().Equal(old.info.ModTime()) {
+		return false, nil
+	}
+
+	mdb, err := openManifestDB(m.path)
+	if err != nil {
+		return false, err
+	}
+	m.warmUp(mdb)
+
+	m.mu.Lock()
+	old = m.current
+	m.current = mdb
+	m.mu.Unlock()
+	log.Printf("switched manifest from %v to %v; cache stats: %+v", old.version, mdb.version, m.CacheStats())
+
+	go func() {
+		old.inFlight.Wait()
+		if err := old.close(); err != nil {
+			log.Printf("failed to close manifest %v: %v", old.version, err)
+		}
+	}()
+	return true, nil
+}
+
+// Watch calls Reload every interval.  It never returns.
+func (m *Manifest) Watch(interval time.Duration) {
+	for range time.Tick(interval) {
+		if _, err := m.Reload(); err != nil {
+			log.Printf("failed to reload manifest: %v", err)
+		}
+	}
+}
+
+func (m *Manifest) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.current.inFlight.Wait()
+	return m.current.close()
+}
+
+// acquire returns the current database.  The caller must call
+// mdb.inFlight.Done when it's finished with it.
+func (m *Manifest) acquire() *manifestDB {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	mdb := m.current
+	mdb.inFlight.Add(1)
+	return mdb
+}
+
+func (m *Manifest) CacheStats() CacheStats {
+	return m.cache.stats()
+}
+
+// warmUp loads the definitions of the WarmUpVendors and their items into the
+// cache, before the database is used.
+func (m *Manifest) warmUp(mdb *manifestDB) {
+	if len(m.opts.WarmUpVendors) == 0 {
+		return
+	}
+	n := 0
+	for _, vendorHash := range m.opts.WarmUpVendors {
+		definition, err := m.getFrom(mdb, "DestinyVendorDefinition", vendorHash, newDestinyVendorDefinition)
+		if err != nil {
+			log.Printf("unable to warm up vendor %v: %v", vendorHash, err)
+			continue
+		}
+		for _, sale := range definition.(*DestinyVendorDefinition).Sales {
+			if _, err := m.getFrom(mdb, "DestinyInventoryItemDefinition", sale.Item.ItemHash, newDestinyInventoryItemDefinition); err != nil {
+				log.Printf("unable to warm up item %v: %v", sale.Item.ItemHash, err)
+				continue
+			}
+			n++
+		}
+	}
+	log.Printf("warmed up manifest %v with %v items", mdb.version, n)
+}
+
+// NotFoundError is returned when a definition isn't in the manifest, which
+// usually means that the manifest is older than the data from the API.
+type NotFoundError struct {
+	Table   string
+	Hash    uint32
+	Version string
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("%v %v not found in manifest %v", e.Table, e.Hash, e.Version)
+}
+
+// IsNotFound returns whether err is a *NotFoundError.
+func IsNotFound(err error) bool {
+	_, ok := err.(*NotFoundError)
+	return ok
+}
+
+func (m *Manifest) get(table string, hash uint32, newDefinition func() interface{}) (interface{}, error) {
+	mdb := m.acquire()
+	defer mdb.inFlight.Done()
+	return m.getFrom(mdb, table, hash, newDefinition)
+}
+
+func (m *Manifest) getFrom(mdb *manifestDB, table string, hash uint32, newDefinition func() interface{}) (interface{}, error) {
+	key := cacheKey{mdb.version, table, hash}
+	if definition, ok := m.cache.get(key); ok {
+		return definition, nil
+	}
+
+	stmt, ok := mdb.stmts[table]
+	if !ok {
+		// The table isn't in this manifest.
+		return nil, &NotFoundError{table, hash, mdb.version}
+	}
 	var value string
-	if err := m.stmts[table].QueryRow(int32(hash)).Scan(&value); err != nil {
-		panic(err)
+	if err := stmt.QueryRow(HashToID(hash)).Scan(&value); err == sql.ErrNoRows {
+		return nil, &NotFoundError{table, hash, mdb.version}
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to select %v %v: %v", table, hash, err)
 	}
+	definition := newDefinition()
 	if err := json.NewDecoder(bytes.NewBuffer([]byte(value))).Decode(definition); err != nil {
-		panic(err)
+		return nil, fmt.Errorf("failed to decode %v %v: %v", table, hash, err)
 	}
+	m.cache.add(key, definition)
+	return definition, nil
 }
 
 type DestinyVendorDefinition struct {
@@ -62,23 +297,82 @@ type DestinyVendorDefinition struct {
 		VendorIdentifier string `json:"vendorIdentifier"`
 		VendorName       string `json:"vendorName"`
 	} `json:"summary"`
+	Sales []struct {
+		Item struct {
+			ItemHash uint32 `json:"itemHash"`
+		} `json:"item"`
+	} `json:"sales"`
 	Hash uint32 `json:"hash"`
 }
 
-func (m *Manifest) GetDestinyVendorDefinition(vendorHash uint32) *DestinyVendorDefinition {
-	definition := new(DestinyVendorDefinition)
-	m.get("DestinyVendorDefinition", vendorHash, definition)
-	return definition
+func newDestinyVendorDefinition() interface{} {
+	return new(DestinyVendorDefinition)
 }
 
+func (m *Manifest) GetDestinyVendorDefinition(vendorHash uint32) (*DestinyVendorDefinition, error) {
+	definition, err := m.get("DestinyVendorDefinition", vendorHash, newDestinyVendorDefinition)
+	if err != nil {
+		return nil, err
+	}
+	return definition.(*DestinyVendorDefinition), nil
+}
+
+// TierType is the rarity of an item.
+type TierType int
+
+const (
+	TierTypeUnknown  TierType = 0
+	TierTypeCurrency TierType = 1
+	TierTypeBasic    TierType = 2
+	TierTypeCommon   TierType = 3
+	TierTypeRare     TierType = 4
+	TierTypeSuperior TierType = 5 // Legendary
+	TierTypeExotic   TierType = 6
+)
+
 type DestinyInventoryItemDefinition struct {
-	Icon         string   `json:"icon"`
-	ItemName     string   `json:"itemName"`
-	SourceHashes []uint32 `json:"sourceHashes"`
+	Icon            string   `json:"icon"`
+	SecondaryIcon   string   `json:"secondaryIcon"`
+	Screenshot      string   `json:"screenshot"`
+	ItemName        string   `json:"itemName"`
+	ItemDescription string   `json:"itemDescription"`
+	ItemTypeName    string   `json:"itemTypeName"`
+	TierType        TierType `json:"tierType"`
+	TierTypeName    string   `json:"tierTypeName"`
+	SourceHashes    []uint32 `json:"sourceHashes"`
+}
+
+func newDestinyInventoryItemDefinition() interface{} {
+	return new(DestinyInventoryItemDefinition)
+}
+
+func (m *Manifest) GetDestinyInventoryItemDefinition(itemHash uint32) (*DestinyInventoryItemDefinition, error) {
+	definition, err := m.get("DestinyInventoryItemDefinition", itemHash, newDestinyInventoryItemDefinition)
+	if err != nil {
+		return nil, err
+	}
+	return definition.(*DestinyInventoryItemDefinition), nil
+}
+
+// DestinyRewardSourceDefinition is a source of items, like a raid, an event
+// or a vendor.
+type DestinyRewardSourceDefinition struct {
+	SourceHash  uint32 `json:"sourceHash"`
+	Category    int    `json:"category"`
+	SourceName  string `json:"sourceName"`
+	Description string `json:"description"`
+	Icon        string `json:"icon"`
+	Identifier  string `json:"identifier"`
 }
 
-func (m *Manifest) GetDestinyInventoryItemDefinition(itemHash uint32) *DestinyInventoryItemDefinition {
-	definition := new(DestinyInventoryItemDefinition)
-	m.get("DestinyInventoryItemDefinition", itemHash, definition)
-	return definition
+func newDestinyRewardSourceDefinition() interface{} {
+	return new(DestinyRewardSourceDefinition)
+}
+
+func (m *Manifest) GetDestinyRewardSourceDefinition(sourceHash uint32) (*DestinyRewardSourceDefinition, error) {
+	definition, err := m.get("DestinyRewardSourceDefinition", sourceHash, newDestinyRewardSourceDefinition)
+	if err != nil {
+		return nil, err
+	}
+	return definition.(*DestinyRewardSourceDefinition), nil
 }
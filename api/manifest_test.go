@@ -0,0 +1,69 @@
+package api
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestManifestWithoutRewardSources(t *testing.T) {
+	dir, err := ioutil.TempDir("", "manifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	rows := map[string]map[uint32]string{
+		"DestinyInventoryItemDefinition": {3000000000: `{"itemName": "Ship", "sourceHashes": [7]}`},
+		"DestinyRewardSourceDefinition":  {7: `{"sourceName": "Raid"}`},
+	}
+	withSources := filepath.Join(dir, "with_sources.content")
+	writeManifestDB(t, withSources, manifestTables, rows)
+	withoutSources := filepath.Join(dir, "without_sources.content")
+	writeManifestDB(t, withoutSources, manifestTables[:2], rows)
+
+	link := filepath.Join(dir, "manifest.db")
+	if err := os.Symlink(filepath.Base(withSources), link); err != nil {
+		t.Fatal(err)
+	}
+	m, err := NewManifest(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	if _, err := m.GetDestinyRewardSourceDefinition(7); err != nil {
+		t.Fatalf("reward source in a manifest with sources: %v", err)
+	}
+
+	// Switch to the manifest without the reward sources.
+	if err := os.Remove(link); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Base(withoutSources), link); err != nil {
+		t.Fatal(err)
+	}
+	if reloaded, err := m.Reload(); err != nil {
+		t.Fatalf("can't reload a manifest without reward sources: %v", err)
+	} else if !reloaded {
+		t.Fatal("manifest wasn't reloaded")
+	}
+
+	if _, err := m.GetDestinyRewardSourceDefinition(7); !IsNotFound(err) {
+		t.Errorf("got %v for a reward source, want a NotFoundError", err)
+	}
+	item, err := m.GetDestinyInventoryItemDefinition(3000000000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.ItemName != "Ship" {
+		t.Errorf("got item %+v", item)
+	}
+
+	// Opening it directly works too.
+	m2, err := NewManifest(withoutSources)
+	if err != nil {
+		t.Fatalf("can't open a manifest without reward sources: %v", err)
+	}
+	m2.Close()
+}
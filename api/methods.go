@@ -2,10 +2,12 @@ package api
 
 import (
 	"fmt"
+	"net/url"
 )
 
 type Request interface {
-	URL() string
+	// Path returns the path of the request, relative to the Bungie base URL.
+	Path() string
 }
 
 type Response interface {
@@ -23,8 +25,8 @@ type Header struct {
 
 type GetCurrentBungieAccountRequest struct{}
 
-func (*GetCurrentBungieAccountRequest) URL() string {
-	return "https://www.bungie.net/Platform/User/GetCurrentBungieAccount/"
+func (*GetCurrentBungieAccountRequest) Path() string {
+	return "/Platform/User/GetCurrentBungieAccount/"
 }
 
 type GetCurrentBungieAccountResponse struct {
@@ -58,10 +60,15 @@ type MyCharacterVendorDataRequest struct {
 	MembershipType int64
 	CharacterHash  string
 	VendorHash     uint32
+	Locale         string
 }
 
-func (r *MyCharacterVendorDataRequest) URL() string {
-	return fmt.Sprintf("https://www.bungie.net/Platform/Destiny/%v/MyAccount/Character/%v/Vendor/%v/", r.MembershipType, r.CharacterHash, r.VendorHash)
+func (r *MyCharacterVendorDataRequest) Path() string {
+	path := fmt.Sprintf("/Platform/Destiny/%v/MyAccount/Character/%v/Vendor/%v/", r.MembershipType, r.CharacterHash, r.VendorHash)
+	if r.Locale != "" {
+		path += "?lc=" + url.QueryEscape(r.Locale)
+	}
+	return path
 }
 
 type MyCharacterVendorDataResponse struct {
@@ -96,8 +103,8 @@ type GetAllVendorsForCurrentCharacterRequest struct {
 	CharacterHash  string
 }
 
-func (r *GetAllVendorsForCurrentCharacterRequest) URL() string {
-	return fmt.Sprintf("https://www.bungie.net/Platform/Destiny/%v/MyAccount/Character/%v/Vendors/Summaries/", r.MembershipType, r.CharacterHash)
+func (r *GetAllVendorsForCurrentCharacterRequest) Path() string {
+	return fmt.Sprintf("/Platform/Destiny/%v/MyAccount/Character/%v/Vendors/Summaries/", r.MembershipType, r.CharacterHash)
 }
 
 type GetAllVendorsForCurrentCharacterResponse struct {
@@ -116,3 +123,21 @@ type GetAllVendorsForCurrentCharacterResponse struct {
 func (r *GetAllVendorsForCurrentCharacterResponse) GetHeader() *Header {
 	return &r.Header
 }
+
+type GetManifestRequest struct{}
+
+func (*GetManifestRequest) Path() string {
+	return "/Platform/Destiny/Manifest/"
+}
+
+type GetManifestResponse struct {
+	Header
+	Response struct {
+		Version                 string            `json:"version"`
+		MobileWorldContentPaths map[string]string `json:"mobileWorldContentPaths"`
+	} `json:"Response"`
+}
+
+func (r *GetManifestResponse) GetHeader() *Header {
+	return &r.Header
+}
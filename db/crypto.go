@@ -0,0 +1,220 @@
+package db
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+const (
+	// TokenKeysEnv is the environment variable that LoadKeyring reads the
+	// keys from if they aren't given any other way.
+	TokenKeysEnv = "DESTINYKIOSKSTATUS_TOKEN_KEYS"
+
+	// encryptedPrefix marks encrypted values.  Values without it were
+	// written before tokens were encrypted, and are read as plaintext.
+	encryptedPrefix = "enc1:"
+)
+
+// A Keyring holds the master keys that encrypt the OAuth tokens in the db.
+//
+// Each token is encrypted with its own random data key, and the data key is
+// encrypted ("wrapped") with a master key.  The stored value is tagged with the
+// ID of the master key, so that old keys can still decrypt values after a new
+// primary key is added; ReencryptTokens then rewraps everything with the
+// primary key so that the old keys can be removed.
+type Keyring struct {
+	primary string
+	keys    map[string][]byte
+}
+
+// ParseKeyring parses a comma-separated list of id=key pairs, where each key
+// is 32 base64-encoded bytes.  The first key is the primary key, which is used
+// to encrypt new values.
+func ParseKeyring(spec string) (*Keyring, error) {
+	k := &Keyring{keys: make(map[string][]byte)}
+	for _, pair := range strings.Split(strings.TrimSpace(spec), ",") {
+		kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(kv) != 2 || kv[0] == "" || strings.Contains(kv[0], ":") {
+			return nil, errors.New("token keys must be comma-separated id=key pairs")
+		}
+		key, err := base64.StdEncoding.DecodeString(kv[1])
+		if err != nil {
+			return nil, fmt.Errorf("token key %v isn't base64: %v", kv[0], err)
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("token key %v must be 32 bytes, not %v", kv[0], len(key))
+		}
+		if _, ok := k.keys[kv[0]]; ok {
+			return nil, fmt.Errorf("duplicate token key %v", kv[0])
+		}
+		if k.primary == "" {
+			k.primary = kv[0]
+		}
+		k.keys[kv[0]] = key
+	}
+	return k, nil
+}
+
+// LoadKeyring parses the keys from spec if it isn't empty, otherwise from the
+// file at path if it isn't empty, otherwise from the TokenKeysEnv environment
+// variable.
+func LoadKeyring(spec, path string) (*Keyring, error) {
+	if spec == "" && path != "" {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read token keys: %v", err)
+		}
+		spec = string(b)
+	}
+	if spec == "" {
+		spec = os.Getenv(TokenKeysEnv)
+	}
+	if spec == "" {
+		return nil, fmt.Errorf("no token keys; set them with a flag, a file or $%v", TokenKeysEnv)
+	}
+	return ParseKeyring(spec)
+}
+
+// encrypt returns the encrypted value, tagged with the primary key's ID.  The
+// value is bound to the user's membership ID, so that it can't be decrypted as
+// another user's token if it's copied to their row.
+func (k *Keyring) encrypt(membershipID BungieMembershipID, plaintext string) (string, error) {
+	dataKey := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return "", err
+	}
+	wrappedKey, err := seal(k.keys[k.primary], dataKey, []byte(membershipID))
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := seal(dataKey, []byte(plaintext), []byte(membershipID))
+	if err != nil {
+		return "", err
+	}
+	return encryptedPrefix + k.primary + ":" + base64.StdEncoding.EncodeToString(wrappedKey) + ":" + base64.StdEncoding.EncodeToString(ciphertext), nil
+}
+
+// decrypt returns the plaintext of a value that encrypt encrypted for the user,
+// or the value itself if it was stored before tokens were encrypted.
+func (k *Keyring) decrypt(membershipID BungieMembershipID, value string) (string, error) {
+	if !strings.HasPrefix(value, encryptedPrefix) {
+		return value, nil
+	}
+	parts := strings.Split(strings.TrimPrefix(value, encryptedPrefix), ":")
+	if len(parts) != 3 {
+		return "", errors.New("malformed encrypted token")
+	}
+	masterKey, ok := k.keys[parts[0]]
+	if !ok {
+		return "", fmt.Errorf("token is encrypted with unknown key %v", parts[0])
+	}
+	wrappedKey, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", err
+	}
+	dataKey, err := open(masterKey, wrappedKey, []byte(membershipID))
+	if err != nil {
+		return "", fmt.Errorf("failed to unwrap token key: %v", err)
+	}
+	plaintext, err := open(dataKey, ciphertext, []byte(membershipID))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt token: %v", err)
+	}
+	return string(plaintext), nil
+}
+
+// seal encrypts plaintext with AES-GCM and prepends the nonce.  The same
+// additionalData must be given to open.
+func seal(key, plaintext, additionalData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func open(key, sealed, additionalData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("sealed value is too short")
+	}
+	return gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], additionalData)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// ReencryptTokens encrypts every stored token with the primary key, including
+// tokens that were stored in plaintext, and returns the number of users that
+// were updated.  Once it has run, keys other than the primary key can be
+// removed from the keyring.
+func (db *DB) ReencryptTokens() (int, error) {
+	tx, err := db.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	type row struct {
+		membershipID, accessToken, refreshToken string
+	}
+	var rows []row
+	r, err := tx.Query("SELECT MembershipID, TokenAccessToken, TokenRefreshToken FROM BungieUsers;")
+	if err != nil {
+		return 0, err
+	}
+	for r.Next() {
+		var x row
+		if err := r.Scan(&x.membershipID, &x.accessToken, &x.refreshToken); err != nil {
+			r.Close()
+			return 0, err
+		}
+		rows = append(rows, x)
+	}
+	if err := r.Err(); err != nil {
+		return 0, err
+	}
+	r.Close()
+
+	for _, x := range rows {
+		var tokens [2]string
+		for i, value := range []string{x.accessToken, x.refreshToken} {
+			plaintext, err := db.keyring.decrypt(BungieMembershipID(x.membershipID), value)
+			if err != nil {
+				return 0, fmt.Errorf("failed to decrypt token for %v: %v", x.membershipID, err)
+			}
+			if tokens[i], err = db.keyring.encrypt(BungieMembershipID(x.membershipID), plaintext); err != nil {
+				return 0, err
+			}
+		}
+		if _, err := tx.Exec(db.dialect.rebind("UPDATE BungieUsers SET TokenAccessToken = ?, TokenRefreshToken = ? WHERE MembershipID = ?;"), tokens[0], tokens[1], x.membershipID); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(rows), tx.Commit()
+}
@@ -0,0 +1,35 @@
+package db
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestKeyringBindsTokensToUsers(t *testing.T) {
+	keyring, err := ParseKeyring("test=" + base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	value, err := keyring.encrypt("1", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(value, encryptedPrefix) || strings.Contains(value, "secret") {
+		t.Fatalf("token wasn't encrypted: %q", value)
+	}
+	if got, err := keyring.decrypt("1", value); err != nil || got != "secret" {
+		t.Errorf("decrypt = %q, %v; want %q", got, err, "secret")
+	}
+
+	// A token copied to another user's row mustn't decrypt.
+	if got, err := keyring.decrypt("2", value); err == nil {
+		t.Errorf("decrypted another user's token as %q", got)
+	}
+
+	// Tokens stored before encryption are read as they are.
+	if got, err := keyring.decrypt("2", "plain"); err != nil || got != "plain" {
+		t.Errorf("decrypt = %q, %v; want %q", got, err, "plain")
+	}
+}
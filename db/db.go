@@ -3,8 +3,7 @@ package db
 import (
 	"database/sql"
 	"fmt"
-
-	_ "github.com/mattn/go-sqlite3"
+	"strings"
 )
 
 type (
@@ -16,32 +15,39 @@ const (
 	tableBungieUsers       tableEnum = "BungieUsers"
 	tableDestinyUsers      tableEnum = "DestinyUsers"
 	tableDestinyCharacters tableEnum = "DestinyCharacters"
+	tableUnsubscriptions   tableEnum = "Unsubscriptions"
+	tableEmails            tableEnum = "Emails"
+	tablePreferences       tableEnum = "Preferences"
+	tableSnapshots         tableEnum = "Snapshots"
+	tableSnapshotItems     tableEnum = "SnapshotItems"
+	tableShares            tableEnum = "Shares"
+	tableWishlists         tableEnum = "Wishlists"
 
-	stmtCreate stmtEnum = "CREATE"
 	stmtInsert stmtEnum = "INSERT"
 	stmtSelect stmtEnum = "SELECT"
+	stmtDelete stmtEnum = "DELETE"
+	stmtUpdate stmtEnum = "UPDATE"
+
+	stmtSelectLatest stmtEnum = "SELECT_LATEST"
+	stmtSelectByUser stmtEnum = "SELECT_BY_USER"
 )
 
 var (
 	tableStmtSQL = map[tableEnum]map[stmtEnum]string{
 		tableBungieUsers: {
-			stmtCreate: `
-CREATE TABLE IF NOT EXISTS BungieUsers(
-    MembershipID      TEXT PRIMARY KEY,
-    DisplayName       TEXT,
-    TokenAccessToken  TEXT,
-    TokenRefreshToken TEXT,
-    TokenExpiry       DATETIME
-);
-`,
 			stmtInsert: `
-INSERT OR REPLACE INTO BungieUsers(
+INSERT INTO BungieUsers(
     MembershipID,
     DisplayName,
     TokenAccessToken,
     TokenRefreshToken,
     TokenExpiry
-) VALUES(?, ?, ?, ?, ?);
+) VALUES(?, ?, ?, ?, ?)
+ON CONFLICT (MembershipID) DO UPDATE SET
+    DisplayName = excluded.DisplayName,
+    TokenAccessToken = excluded.TokenAccessToken,
+    TokenRefreshToken = excluded.TokenRefreshToken,
+    TokenExpiry = excluded.TokenExpiry;
 `,
 			stmtSelect: `
 SELECT
@@ -57,24 +63,16 @@ WHERE
 		},
 
 		tableDestinyUsers: {
-			stmtCreate: `
-CREATE TABLE IF NOT EXISTS DestinyUsers(
-    MembershipType     INT64,
-    MembershipID       TEXT,
-    DisplayName        TEXT,
-    BungieMembershipID TEXT,
-    PRIMARY KEY (MembershipType, MembershipID)
-);
-CREATE INDEX IF NOT EXISTS DestinyUsers_BungieMembershipID
-ON DestinyUsers (BungieMembershipID);
-`,
 			stmtInsert: `
-INSERT OR REPLACE INTO DestinyUsers(
+INSERT INTO DestinyUsers(
     MembershipType,
     MembershipID,
     DisplayName,
     BungieMembershipID
-) VALUES(?, ?, ?, ?);
+) VALUES(?, ?, ?, ?)
+ON CONFLICT (MembershipType, MembershipID) DO UPDATE SET
+    DisplayName = excluded.DisplayName,
+    BungieMembershipID = excluded.BungieMembershipID;
 `,
 			stmtSelect: `
 SELECT
@@ -85,27 +83,27 @@ FROM
     DestinyUsers
 WHERE
     BungieMembershipID = ?;
+`,
+			stmtDelete: `
+DELETE FROM
+    DestinyUsers
+WHERE
+    BungieMembershipID = ?;
 `,
 		},
 
 		tableDestinyCharacters: {
-			stmtCreate: `
-CREATE TABLE IF NOT EXISTS DestinyCharacters(
-    CharacterID           TEXT PRIMARY KEY,
-    ClassName             TEXT,
-    DestinyMembershipType INT64,
-    DestinyMembershipID   TEXT
-);
-CREATE INDEX IF NOT EXISTS DestinyCharacters_DestinyMembershipType_DestinyMembershipID
-ON DestinyCharacters (DestinyMembershipType, DestinyMembershipID);
-`,
 			stmtInsert: `
-INSERT OR REPLACE INTO DestinyCharacters(
+INSERT INTO DestinyCharacters(
     CharacterID,
     ClassName,
     DestinyMembershipType,
     DestinyMembershipID
-) VALUES(?, ?, ?, ?);
+) VALUES(?, ?, ?, ?)
+ON CONFLICT (CharacterID) DO UPDATE SET
+    ClassName = excluded.ClassName,
+    DestinyMembershipType = excluded.DestinyMembershipType,
+    DestinyMembershipID = excluded.DestinyMembershipID;
 `,
 			stmtSelect: `
 SELECT
@@ -116,28 +114,305 @@ FROM
 WHERE
     DestinyMembershipType = ? AND
     DestinyMembershipID = ?;
+`,
+			// Deletes the characters of all the DestinyUsers of a BungieUser.
+			stmtDelete: `
+DELETE FROM
+    DestinyCharacters
+WHERE
+    EXISTS (
+        SELECT 1
+        FROM DestinyUsers
+        WHERE
+            DestinyUsers.MembershipType = DestinyCharacters.DestinyMembershipType AND
+            DestinyUsers.MembershipID = DestinyCharacters.DestinyMembershipID AND
+            DestinyUsers.BungieMembershipID = ?
+    );
+`,
+		},
+
+		tableUnsubscriptions: {
+			stmtInsert: `
+INSERT INTO Unsubscriptions(
+    BungieMembershipID,
+    VendorHash
+) VALUES(?, ?)
+ON CONFLICT (BungieMembershipID, VendorHash) DO NOTHING;
+`,
+			stmtSelect: `
+SELECT
+    VendorHash
+FROM
+    Unsubscriptions
+WHERE
+    BungieMembershipID = ?;
+`,
+		},
+
+		tableEmails: {
+			stmtInsert: `
+INSERT INTO Emails(
+    BungieMembershipID,
+    Address,
+    Verified
+) VALUES(?, ?, ?)
+ON CONFLICT (BungieMembershipID) DO UPDATE SET
+    Address = excluded.Address,
+    Verified = excluded.Verified;
+`,
+			stmtSelect: `
+SELECT
+    Address,
+    Verified
+FROM
+    Emails
+WHERE
+    BungieMembershipID = ?;
+`,
+		},
+
+		tablePreferences: {
+			stmtInsert: `
+INSERT INTO Preferences(
+    BungieMembershipID,
+    Language
+) VALUES(?, ?)
+ON CONFLICT (BungieMembershipID) DO UPDATE SET
+    Language = excluded.Language;
+`,
+			stmtSelect: `
+SELECT
+    Language
+FROM
+    Preferences
+WHERE
+    BungieMembershipID = ?;
+`,
+		},
+
+		tableSnapshots: {
+			stmtInsert: `
+INSERT INTO Snapshots(
+    CharacterID,
+    VendorHash,
+    TakenAt
+) VALUES(?, ?, ?)
+RETURNING ID;
+`,
+			stmtSelect: `
+SELECT
+    ID,
+    TakenAt
+FROM
+    Snapshots
+WHERE
+    CharacterID = ? AND
+    VendorHash = ?
+ORDER BY
+    TakenAt, ID;
+`,
+			stmtSelectLatest: `
+SELECT
+    ID,
+    TakenAt
+FROM
+    Snapshots
+WHERE
+    CharacterID = ? AND
+    VendorHash = ?
+ORDER BY
+    TakenAt DESC, ID DESC
+LIMIT 1;
+`,
+		},
+
+		tableSnapshotItems: {
+			stmtInsert: `
+INSERT INTO SnapshotItems(
+    SnapshotID,
+    ItemHash,
+    Unlocked,
+    Category,
+    Position
+) VALUES(?, ?, ?, ?, ?);
+`,
+			stmtSelect: `
+SELECT
+    ItemHash,
+    Unlocked,
+    Category
+FROM
+    SnapshotItems
+WHERE
+    SnapshotID = ?
+ORDER BY
+    Position, ItemHash;
+`,
+		},
+
+		tableShares: {
+			stmtInsert: `
+INSERT INTO Shares(
+    ID,
+    BungieMembershipID,
+    Owner,
+    CharacterID,
+    ClassName,
+    VendorHash,
+    CreatedAt
+) VALUES(?, ?, ?, ?, ?, ?, ?);
+`,
+			stmtSelect: `
+SELECT
+    BungieMembershipID,
+    Owner,
+    CharacterID,
+    ClassName,
+    VendorHash,
+    CreatedAt
+FROM
+    Shares
+WHERE
+    ID = ?;
+`,
+			stmtSelectByUser: `
+SELECT
+    ID,
+    Owner,
+    CharacterID,
+    ClassName,
+    VendorHash,
+    CreatedAt
+FROM
+    Shares
+WHERE
+    BungieMembershipID = ?
+ORDER BY
+    CreatedAt DESC;
+`,
+			stmtDelete: `
+DELETE FROM
+    Shares
+WHERE
+    ID = ? AND
+    BungieMembershipID = ?;
+`,
+		},
+
+		tableWishlists: {
+			stmtInsert: `
+INSERT INTO Wishlists(
+    BungieMembershipID,
+    ItemHash
+) VALUES(?, ?)
+ON CONFLICT (BungieMembershipID, ItemHash) DO NOTHING;
+`,
+			stmtSelect: `
+SELECT
+    ItemHash,
+    Alerted
+FROM
+    Wishlists
+WHERE
+    BungieMembershipID = ?
+ORDER BY
+    ItemHash;
+`,
+			stmtUpdate: `
+UPDATE
+    Wishlists
+SET
+    Alerted = ?
+WHERE
+    BungieMembershipID = ? AND
+    ItemHash = ?;
+`,
+			stmtDelete: `
+DELETE FROM
+    Wishlists
+WHERE
+    BungieMembershipID = ? AND
+    ItemHash = ?;
 `,
 		},
 	}
 )
 
+// Storage stores the users and their settings.  Open returns the
+// implementation for a DSN.
+type Storage interface {
+	SelectBungieUser(membershipID BungieMembershipID) (*BungieUser, error)
+	InsertBungieUser(bungieUser *BungieUser) error
+
+	SelectSubscription(membershipID BungieMembershipID) (*Subscription, error)
+	InsertUnsubscription(membershipID BungieMembershipID, vendorHash uint32) error
+
+	SelectEmail(membershipID BungieMembershipID) (*Email, error)
+	InsertEmail(membershipID BungieMembershipID, email *Email) error
+
+	SelectPreferences(membershipID BungieMembershipID) (*Preferences, error)
+	InsertPreferences(membershipID BungieMembershipID, preferences *Preferences) error
+
+	InsertSnapshot(snapshot *Snapshot) (bool, error)
+	SelectLatestSnapshot(characterID DestinyCharacterID, vendorHash uint32) (*Snapshot, error)
+	SelectSnapshots(characterID DestinyCharacterID, vendorHash uint32) ([]*Snapshot, error)
+
+	InsertShare(share *Share) error
+	SelectShare(id string) (*Share, error)
+	SelectShares(membershipID BungieMembershipID) ([]*Share, error)
+	DeleteShare(membershipID BungieMembershipID, id string) error
+
+	SelectWishlist(membershipID BungieMembershipID) (*Wishlist, error)
+	InsertWishlistItem(membershipID BungieMembershipID, itemHash uint32) error
+	DeleteWishlistItem(membershipID BungieMembershipID, itemHash uint32) error
+	UpdateWishlistAlerted(membershipID BungieMembershipID, itemHash uint32, alerted bool) error
+
+	ReencryptTokens() (int, error)
+	Close() error
+}
+
 type table struct {
 	stmts map[stmtEnum]*sql.Stmt
 }
 
+// DB is the database/sql implementation of Storage.  The statements in
+// tableStmtSQL are shared by every dialect, so they're limited to SQL that
+// both SQLite and PostgreSQL understand.
 type DB struct {
-	db *sql.DB
+	db      *sql.DB
+	dialect *dialect
+	keyring *Keyring
 
 	tables map[tableEnum]*table
 }
 
-func NewDB(path string) (*DB, error) {
+// Open opens the database for dsn, which is either a postgres:// (or
+// postgresql://) URL or the path of a SQLite database, optionally prefixed
+// with sqlite3://.  The OAuth tokens are encrypted with the keys in keyring.
+func Open(dsn string, keyring *Keyring) (Storage, error) {
+	d, dataSource := parseDSN(dsn)
+	return newDB(d, dataSource, keyring)
+}
+
+func parseDSN(dsn string) (*dialect, string) {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		return postgres, dsn
+	}
+	return sqlite, strings.TrimPrefix(dsn, "sqlite3://")
+}
+
+func newDB(d *dialect, dataSource string, keyring *Keyring) (*DB, error) {
+	if keyring == nil {
+		return nil, fmt.Errorf("no keyring for db")
+	}
 	db := &DB{
-		tables: make(map[tableEnum]*table),
+		dialect: d,
+		keyring: keyring,
+		tables:  make(map[tableEnum]*table),
 	}
 
 	// Create the database connection.
-	if sqldb, err := sql.Open("sqlite3", path); err != nil {
+	if sqldb, err := sql.Open(d.driver, dataSource); err != nil {
 		return nil, fmt.Errorf("failed to open db: %v", err)
 	} else if sqldb == nil {
 		return nil, fmt.Errorf("db is nil")
@@ -145,22 +420,21 @@ func NewDB(path string) (*DB, error) {
 		db.db = sqldb
 	}
 
+	// Bring the schema up to date.
+	if err := migrate(d, db.db); err != nil {
+		db.db.Close()
+		return nil, err
+	}
+
 	for tbl, stmts := range tableStmtSQL {
 		db.tables[tbl] = &table{
 			stmts: make(map[stmtEnum]*sql.Stmt),
 		}
 
-		// Create the table.
-		if _, err := db.db.Exec(stmts[stmtCreate]); err != nil {
-			return nil, fmt.Errorf("failed to create table %v: %v", tbl, err)
-		}
-
 		// Prepare all the statements.
 		for stmt, sql := range stmts {
-			if stmt == stmtCreate {
-				continue
-			}
-			if s, err := db.db.Prepare(sql); err != nil {
+			if s, err := db.db.Prepare(d.rebind(sql)); err != nil {
+				db.Close()
 				return nil, fmt.Errorf("failed to prepare %v on table %v: %v", stmt, tbl, err)
 			} else {
 				db.tables[tbl].stmts[stmt] = s
@@ -170,3 +444,18 @@ func NewDB(path string) (*DB, error) {
 
 	return db, nil
 }
+
+func (db *DB) Close() error {
+	for _, t := range db.tables {
+		for _, stmt := range t.stmts {
+			stmt.Close()
+		}
+	}
+	return db.db.Close()
+}
+
+// txStmt returns the prepared statement stmt of tbl bound to tx.  The returned
+// statement is closed when tx is committed or rolled back.
+func (db *DB) txStmt(tx *sql.Tx, tbl tableEnum, stmt stmtEnum) *sql.Stmt {
+	return tx.Stmt(db.tables[tbl].stmts[stmt])
+}
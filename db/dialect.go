@@ -0,0 +1,49 @@
+package db
+
+import (
+	"strconv"
+	"strings"
+)
+
+// A dialect is what differs between the databases that DB supports.
+type dialect struct {
+	// driver is the database/sql driver name.
+	driver string
+
+	// migrations are the schema migrations, which must describe the same
+	// schema in every dialect.
+	migrations []migration
+
+	// createSchemaVersionSQL creates the schema_version table, and
+	// hasSchemaVersionSQL counts the schema_version tables without creating
+	// one.
+	createSchemaVersionSQL string
+	hasSchemaVersionSQL    string
+
+	// lockSchemaVersionSQL, if set, is run at the start of each migration's
+	// transaction so that servers starting at the same time don't apply the
+	// same migration twice.
+	lockSchemaVersionSQL string
+
+	// numberedParams is whether the parameters are $1, $2, ... instead of ?.
+	numberedParams bool
+}
+
+// rebind rewrites the ? parameters of query for the dialect.  The queries in
+// this package never contain a literal ?.
+func (d *dialect) rebind(query string) string {
+	if !d.numberedParams {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
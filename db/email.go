@@ -0,0 +1,31 @@
+package db
+
+import (
+	"database/sql"
+)
+
+type Email struct {
+	Address  string
+	Verified bool
+}
+
+// SelectEmail returns the user's email, or nil if the user hasn't set one.
+func (db *DB) SelectEmail(membershipID BungieMembershipID) (*Email, error) {
+	email := new(Email)
+	err := db.tables[tableEmails].stmts[stmtSelect].QueryRow(string(membershipID)).Scan(&email.Address, &email.Verified)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return email, nil
+}
+
+func (db *DB) InsertEmail(membershipID BungieMembershipID, email *Email) error {
+	_, err := db.tables[tableEmails].stmts[stmtInsert].Exec(
+		string(membershipID),
+		email.Address,
+		email.Verified,
+	)
+	return err
+}
@@ -0,0 +1,149 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+// A migration changes the schema.  Each dialect has its own list of
+// migrations, which are applied in order, each in its own transaction;
+// applying migrations[i] brings the schema to version i+1.  Never change a
+// migration once it has been released; add a new one, to every dialect,
+// instead.
+type migration struct {
+	description string
+	sql         string
+}
+
+// MigrationStatus is whether a migration has been applied to a database.
+type MigrationStatus struct {
+	Version     int
+	Description string
+	Applied     bool
+	AppliedAt   time.Time
+}
+
+// Migrate applies the migrations that haven't been applied to the database
+// for dsn (see Open).  Open does this too.
+func Migrate(dsn string) error {
+	d, dataSource := parseDSN(dsn)
+	sqldb, err := sql.Open(d.driver, dataSource)
+	if err != nil {
+		return fmt.Errorf("failed to open db: %v", err)
+	}
+	defer sqldb.Close()
+	return migrate(d, sqldb)
+}
+
+// Status returns the status of every migration for the database for dsn,
+// without applying any of them.
+func Status(dsn string) ([]MigrationStatus, error) {
+	d, dataSource := parseDSN(dsn)
+	sqldb, err := sql.Open(d.driver, dataSource)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open db: %v", err)
+	}
+	defer sqldb.Close()
+
+	applied, err := appliedMigrations(d, sqldb)
+	if err != nil {
+		return nil, err
+	}
+	var statuses []MigrationStatus
+	for i, m := range d.migrations {
+		appliedAt, ok := applied[i+1]
+		statuses = append(statuses, MigrationStatus{
+			Version:     i + 1,
+			Description: m.description,
+			Applied:     ok,
+			AppliedAt:   appliedAt,
+		})
+	}
+	return statuses, nil
+}
+
+func appliedMigrations(d *dialect, sqldb *sql.DB) (map[int]time.Time, error) {
+	applied := make(map[int]time.Time)
+
+	// Don't create schema_version here, so that Status doesn't modify the db.
+	var n int
+	if err := sqldb.QueryRow(d.hasSchemaVersionSQL).Scan(&n); err != nil {
+		return nil, err
+	} else if n == 0 {
+		return applied, nil
+	}
+
+	rows, err := sqldb.Query("SELECT Version, AppliedAt FROM schema_version;")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return applied, nil
+}
+
+func migrate(d *dialect, sqldb *sql.DB) error {
+	if _, err := sqldb.Exec(d.createSchemaVersionSQL); err != nil {
+		return fmt.Errorf("failed to create schema_version: %v", err)
+	}
+	applied, err := appliedMigrations(d, sqldb)
+	if err != nil {
+		return err
+	}
+	if len(applied) > len(d.migrations) {
+		return fmt.Errorf("db is at schema version %v, but only %v migrations are known", len(applied), len(d.migrations))
+	}
+	for i, m := range d.migrations {
+		version := i + 1
+		if _, ok := applied[version]; ok {
+			continue
+		}
+		if ok, err := applyMigration(d, sqldb, version, m); err != nil {
+			return fmt.Errorf("failed to apply migration %v (%v): %v", version, m.description, err)
+		} else if ok {
+			log.Printf("applied db migration %v: %v", version, m.description)
+		}
+	}
+	return nil
+}
+
+// applyMigration applies m, unless another process applied it first.
+func applyMigration(d *dialect, sqldb *sql.DB, version int, m migration) (bool, error) {
+	tx, err := sqldb.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if d.lockSchemaVersionSQL != "" {
+		if _, err := tx.Exec(d.lockSchemaVersionSQL); err != nil {
+			return false, err
+		}
+	}
+	var n int
+	if err := tx.QueryRow(d.rebind("SELECT COUNT(*) FROM schema_version WHERE Version = ?;"), version).Scan(&n); err != nil {
+		return false, err
+	} else if n > 0 {
+		return false, nil
+	}
+
+	if _, err := tx.Exec(m.sql); err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec(d.rebind("INSERT INTO schema_version(Version, Description, AppliedAt) VALUES(?, ?, ?);"), version, m.description, time.Now()); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
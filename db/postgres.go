@@ -0,0 +1,145 @@
+package db
+
+import (
+	_ "github.com/lib/pq"
+)
+
+var postgres = &dialect{
+	driver:     "postgres",
+	migrations: postgresMigrations,
+	createSchemaVersionSQL: `
+CREATE TABLE IF NOT EXISTS schema_version(
+    Version     INTEGER PRIMARY KEY,
+    Description TEXT,
+    AppliedAt   TIMESTAMPTZ
+);
+`,
+	hasSchemaVersionSQL:  "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = 'schema_version';",
+	lockSchemaVersionSQL: "LOCK TABLE schema_version IN EXCLUSIVE MODE;",
+	numberedParams:       true,
+}
+
+// postgresMigrations match sqliteMigrations one for one.
+var postgresMigrations = []migration{
+	{
+		description: "Create BungieUsers.",
+		sql: `
+CREATE TABLE IF NOT EXISTS BungieUsers(
+    MembershipID      TEXT PRIMARY KEY,
+    DisplayName       TEXT,
+    TokenAccessToken  TEXT,
+    TokenRefreshToken TEXT,
+    TokenExpiry       TIMESTAMPTZ
+);
+`,
+	},
+	{
+		description: "Create DestinyUsers.",
+		sql: `
+CREATE TABLE IF NOT EXISTS DestinyUsers(
+    MembershipType     BIGINT,
+    MembershipID       TEXT,
+    DisplayName        TEXT,
+    BungieMembershipID TEXT,
+    PRIMARY KEY (MembershipType, MembershipID)
+);
+CREATE INDEX IF NOT EXISTS DestinyUsers_BungieMembershipID
+ON DestinyUsers (BungieMembershipID);
+`,
+	},
+	{
+		description: "Create DestinyCharacters.",
+		sql: `
+CREATE TABLE IF NOT EXISTS DestinyCharacters(
+    CharacterID           TEXT PRIMARY KEY,
+    ClassName             TEXT,
+    DestinyMembershipType BIGINT,
+    DestinyMembershipID   TEXT
+);
+CREATE INDEX IF NOT EXISTS DestinyCharacters_DestinyMembershipType_DestinyMembershipID
+ON DestinyCharacters (DestinyMembershipType, DestinyMembershipID);
+`,
+	},
+	{
+		description: "Create Unsubscriptions.",
+		sql: `
+CREATE TABLE IF NOT EXISTS Unsubscriptions(
+    BungieMembershipID TEXT,
+    VendorHash         BIGINT,
+    PRIMARY KEY (BungieMembershipID, VendorHash)
+);
+`,
+	},
+	{
+		description: "Create Emails.",
+		sql: `
+CREATE TABLE IF NOT EXISTS Emails(
+    BungieMembershipID TEXT PRIMARY KEY,
+    Address            TEXT,
+    Verified           BOOLEAN
+);
+`,
+	},
+	{
+		description: "Create Preferences.",
+		sql: `
+CREATE TABLE IF NOT EXISTS Preferences(
+    BungieMembershipID TEXT PRIMARY KEY,
+    Language           TEXT
+);
+`,
+	},
+	{
+		description: "Create Snapshots.",
+		sql: `
+CREATE TABLE Snapshots(
+    ID          BIGSERIAL PRIMARY KEY,
+    CharacterID TEXT,
+    VendorHash  BIGINT,
+    TakenAt     TIMESTAMPTZ
+);
+CREATE INDEX Snapshots_CharacterID_VendorHash
+ON Snapshots (CharacterID, VendorHash);
+CREATE TABLE SnapshotItems(
+    SnapshotID BIGINT,
+    ItemHash   BIGINT,
+    Unlocked   BOOLEAN,
+    PRIMARY KEY (SnapshotID, ItemHash)
+);
+`,
+	},
+	{
+		description: "Add categories to snapshots.",
+		sql: `
+ALTER TABLE SnapshotItems ADD COLUMN Category TEXT NOT NULL DEFAULT '';
+ALTER TABLE SnapshotItems ADD COLUMN Position BIGINT NOT NULL DEFAULT 0;
+`,
+	},
+	{
+		description: "Create Shares.",
+		sql: `
+CREATE TABLE Shares(
+    ID                 TEXT PRIMARY KEY,
+    BungieMembershipID TEXT,
+    Owner              TEXT,
+    CharacterID        TEXT,
+    ClassName          TEXT,
+    VendorHash         BIGINT,
+    CreatedAt          TIMESTAMPTZ
+);
+CREATE INDEX Shares_BungieMembershipID
+ON Shares (BungieMembershipID);
+`,
+	},
+	{
+		description: "Create Wishlists.",
+		sql: `
+CREATE TABLE Wishlists(
+    BungieMembershipID TEXT,
+    ItemHash           BIGINT,
+    Alerted            BOOLEAN NOT NULL DEFAULT FALSE,
+    PRIMARY KEY (BungieMembershipID, ItemHash)
+);
+`,
+	},
+}
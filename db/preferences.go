@@ -0,0 +1,30 @@
+package db
+
+import (
+	"database/sql"
+)
+
+type Preferences struct {
+	// Language is the locale to show item and vendor names in, or "" to use
+	// the browser's language.
+	Language string
+}
+
+// SelectPreferences returns the user's preferences, or the default preferences
+// if the user hasn't set any.
+func (db *DB) SelectPreferences(membershipID BungieMembershipID) (*Preferences, error) {
+	preferences := new(Preferences)
+	err := db.tables[tablePreferences].stmts[stmtSelect].QueryRow(string(membershipID)).Scan(&preferences.Language)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	return preferences, nil
+}
+
+func (db *DB) InsertPreferences(membershipID BungieMembershipID, preferences *Preferences) error {
+	_, err := db.tables[tablePreferences].stmts[stmtInsert].Exec(
+		string(membershipID),
+		preferences.Language,
+	)
+	return err
+}
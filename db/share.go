@@ -0,0 +1,95 @@
+package db
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"time"
+)
+
+// A Share lets anyone with its link see one of a user's kiosks for one of
+// their characters, as it was in the latest snapshot.  Deleting the Share
+// revokes the link.
+type Share struct {
+	ID           string
+	MembershipID BungieMembershipID
+
+	// Owner is the display name of the user.
+	Owner       string
+	CharacterID DestinyCharacterID
+	ClassName   string
+	VendorHash  uint32
+	CreatedAt   time.Time
+}
+
+// InsertShare stores the share with a new random ID, and sets share.ID.
+func (db *DB) InsertShare(share *Share) error {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	id := base64.RawURLEncoding.EncodeToString(b)
+	_, err := db.tables[tableShares].stmts[stmtInsert].Exec(
+		id,
+		string(share.MembershipID),
+		share.Owner,
+		string(share.CharacterID),
+		share.ClassName,
+		int64(share.VendorHash),
+		share.CreatedAt.UTC(),
+	)
+	if err != nil {
+		return err
+	}
+	share.ID = id
+	return nil
+}
+
+// SelectShare returns the share, or nil if it doesn't exist or was revoked.
+func (db *DB) SelectShare(id string) (*Share, error) {
+	share := &Share{ID: id}
+	var membershipID, characterID string
+	var vendorHash int64
+	err := db.tables[tableShares].stmts[stmtSelect].QueryRow(id).Scan(&membershipID, &share.Owner, &characterID, &share.ClassName, &vendorHash, &share.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	share.MembershipID = BungieMembershipID(membershipID)
+	share.CharacterID = DestinyCharacterID(characterID)
+	share.VendorHash = uint32(vendorHash)
+	return share, nil
+}
+
+// SelectShares returns the user's shares, newest first.
+func (db *DB) SelectShares(membershipID BungieMembershipID) ([]*Share, error) {
+	var shares []*Share
+	rows, err := db.tables[tableShares].stmts[stmtSelectByUser].Query(string(membershipID))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		share := &Share{MembershipID: membershipID}
+		var characterID string
+		var vendorHash int64
+		if err := rows.Scan(&share.ID, &share.Owner, &characterID, &share.ClassName, &vendorHash, &share.CreatedAt); err != nil {
+			return nil, err
+		}
+		share.CharacterID = DestinyCharacterID(characterID)
+		share.VendorHash = uint32(vendorHash)
+		shares = append(shares, share)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return shares, nil
+}
+
+// DeleteShare revokes one of the user's shares.  Revoking a share that doesn't
+// exist, or that belongs to another user, does nothing.
+func (db *DB) DeleteShare(membershipID BungieMembershipID, id string) error {
+	_, err := db.tables[tableShares].stmts[stmtDelete].Exec(id, string(membershipID))
+	return err
+}
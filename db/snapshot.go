@@ -0,0 +1,253 @@
+package db
+
+import (
+	"database/sql"
+	"sort"
+	"time"
+)
+
+// A Snapshot is which of a vendor's items a character had unlocked at a point
+// in time.
+type Snapshot struct {
+	CharacterID DestinyCharacterID
+	VendorHash  uint32
+	TakenAt     time.Time
+
+	// Items maps the hash of each of the vendor's items to whether it was
+	// unlocked.
+	Items map[uint32]bool
+
+	// Categories are the vendor's categories and their items, in the order
+	// that the vendor shows them.  Snapshots taken before categories were
+	// recorded have a single category without a title.
+	Categories []SnapshotCategory
+}
+
+type SnapshotCategory struct {
+	Title      string
+	ItemHashes []uint32
+}
+
+// Unlocked returns the number of items that were unlocked.
+func (s *Snapshot) Unlocked() int {
+	n := 0
+	for _, unlocked := range s.Items {
+		if unlocked {
+			n++
+		}
+	}
+	return n
+}
+
+func (s *Snapshot) sameItems(other *Snapshot) bool {
+	if len(s.Items) != len(other.Items) {
+		return false
+	}
+	for itemHash, unlocked := range s.Items {
+		if u, ok := other.Items[itemHash]; !ok || u != unlocked {
+			return false
+		}
+	}
+	categories, otherCategories := s.layout(), other.layout()
+	if len(categories) != len(otherCategories) {
+		return false
+	}
+	for i, category := range categories {
+		o := otherCategories[i]
+		if category.Title != o.Title || len(category.ItemHashes) != len(o.ItemHashes) {
+			return false
+		}
+		for j, itemHash := range category.ItemHashes {
+			if itemHash != o.ItemHashes[j] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// layout returns the categories as they're stored: each item once, with the
+// items that aren't in a category at the end, sorted by hash, in a category
+// without a title.
+func (s *Snapshot) layout() []SnapshotCategory {
+	var categories []SnapshotCategory
+	seen := make(map[uint32]bool)
+	for _, category := range s.Categories {
+		c := SnapshotCategory{Title: category.Title}
+		for _, itemHash := range category.ItemHashes {
+			if _, ok := s.Items[itemHash]; ok && !seen[itemHash] {
+				seen[itemHash] = true
+				c.ItemHashes = append(c.ItemHashes, itemHash)
+			}
+		}
+		if len(c.ItemHashes) > 0 {
+			categories = append(categories, c)
+		}
+	}
+	var rest []uint32
+	for itemHash := range s.Items {
+		if !seen[itemHash] {
+			rest = append(rest, itemHash)
+		}
+	}
+	if len(rest) > 0 {
+		sort.Slice(rest, func(i, j int) bool { return rest[i] < rest[j] })
+		categories = append(categories, SnapshotCategory{ItemHashes: rest})
+	}
+	return categories
+}
+
+// AcquiredAt returns when each item was first unlocked in snapshots, which
+// must be oldest first.  Items that were unlocked in the first snapshot were
+// acquired at some time before it.
+func AcquiredAt(snapshots []*Snapshot) map[uint32]time.Time {
+	acquired := make(map[uint32]time.Time)
+	for _, snapshot := range snapshots {
+		for itemHash, unlocked := range snapshot.Items {
+			if _, ok := acquired[itemHash]; unlocked && !ok {
+				acquired[itemHash] = snapshot.TakenAt
+			}
+		}
+	}
+	return acquired
+}
+
+// InsertSnapshot stores the snapshot, unless the items are the same as in the
+// latest snapshot of the character and vendor, so that only changes are
+// recorded.  It returns whether the snapshot was stored.
+func (db *DB) InsertSnapshot(snapshot *Snapshot) (bool, error) {
+	if len(snapshot.Items) == 0 {
+		return false, nil
+	}
+
+	tx, err := db.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if latest, err := db.selectLatestSnapshot(tx, snapshot.CharacterID, snapshot.VendorHash); err != nil {
+		return false, err
+	} else if latest != nil && latest.sameItems(snapshot) {
+		return false, nil
+	}
+
+	var id int64
+	if err := db.txStmt(tx, tableSnapshots, stmtInsert).QueryRow(
+		string(snapshot.CharacterID),
+		int64(snapshot.VendorHash),
+		// UTC, so that the times sort correctly in databases that store them
+		// as strings.
+		snapshot.TakenAt.UTC(),
+	).Scan(&id); err != nil {
+		return false, err
+	}
+	insertItem := db.txStmt(tx, tableSnapshotItems, stmtInsert)
+	position := 0
+	for _, category := range snapshot.layout() {
+		for _, itemHash := range category.ItemHashes {
+			if _, err := insertItem.Exec(id, int64(itemHash), snapshot.Items[itemHash], category.Title, position); err != nil {
+				return false, err
+			}
+			position++
+		}
+	}
+	return true, tx.Commit()
+}
+
+// SelectLatestSnapshot returns the latest snapshot of the character's items
+// from the vendor, or nil if there isn't one.
+func (db *DB) SelectLatestSnapshot(characterID DestinyCharacterID, vendorHash uint32) (*Snapshot, error) {
+	tx, err := db.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	return db.selectLatestSnapshot(tx, characterID, vendorHash)
+}
+
+func (db *DB) selectLatestSnapshot(tx *sql.Tx, characterID DestinyCharacterID, vendorHash uint32) (*Snapshot, error) {
+	snapshot := &Snapshot{
+		CharacterID: characterID,
+		VendorHash:  vendorHash,
+	}
+	var id int64
+	err := db.txStmt(tx, tableSnapshots, stmtSelectLatest).QueryRow(string(characterID), int64(vendorHash)).Scan(&id, &snapshot.TakenAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := db.selectSnapshotItems(tx, id, snapshot); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// SelectSnapshots returns all the snapshots of the character's items from the
+// vendor, oldest first.
+func (db *DB) SelectSnapshots(characterID DestinyCharacterID, vendorHash uint32) ([]*Snapshot, error) {
+	tx, err := db.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var ids []int64
+	var snapshots []*Snapshot
+	rows, err := db.txStmt(tx, tableSnapshots, stmtSelect).Query(string(characterID), int64(vendorHash))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		snapshot := &Snapshot{
+			CharacterID: characterID,
+			VendorHash:  vendorHash,
+		}
+		if err := rows.Scan(&id, &snapshot.TakenAt); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+		snapshots = append(snapshots, snapshot)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	for i, snapshot := range snapshots {
+		if err := db.selectSnapshotItems(tx, ids[i], snapshot); err != nil {
+			return nil, err
+		}
+	}
+	return snapshots, nil
+}
+
+func (db *DB) selectSnapshotItems(tx *sql.Tx, id int64, snapshot *Snapshot) error {
+	snapshot.Items = make(map[uint32]bool)
+	rows, err := db.txStmt(tx, tableSnapshotItems, stmtSelect).Query(id)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var itemHash int64
+		var unlocked bool
+		var category string
+		if err := rows.Scan(&itemHash, &unlocked, &category); err != nil {
+			return err
+		}
+		snapshot.Items[uint32(itemHash)] = unlocked
+
+		// The items are in order, so a new category starts whenever the
+		// title changes.
+		if n := len(snapshot.Categories); n == 0 || snapshot.Categories[n-1].Title != category {
+			snapshot.Categories = append(snapshot.Categories, SnapshotCategory{Title: category})
+		}
+		c := &snapshot.Categories[len(snapshot.Categories)-1]
+		c.ItemHashes = append(c.ItemHashes, uint32(itemHash))
+	}
+	return rows.Err()
+}
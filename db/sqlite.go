@@ -0,0 +1,144 @@
+package db
+
+import (
+	_ "github.com/mattn/go-sqlite3"
+)
+
+var sqlite = &dialect{
+	driver:     "sqlite3",
+	migrations: sqliteMigrations,
+	createSchemaVersionSQL: `
+CREATE TABLE IF NOT EXISTS schema_version(
+    Version     INTEGER PRIMARY KEY,
+    Description TEXT,
+    AppliedAt   DATETIME
+);
+`,
+	hasSchemaVersionSQL: "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_version';",
+}
+
+// The first migrations use IF NOT EXISTS because databases created before
+// migrations existed may already have their tables.
+var sqliteMigrations = []migration{
+	{
+		description: "Create BungieUsers.",
+		sql: `
+CREATE TABLE IF NOT EXISTS BungieUsers(
+    MembershipID      TEXT PRIMARY KEY,
+    DisplayName       TEXT,
+    TokenAccessToken  TEXT,
+    TokenRefreshToken TEXT,
+    TokenExpiry       DATETIME
+);
+`,
+	},
+	{
+		description: "Create DestinyUsers.",
+		sql: `
+CREATE TABLE IF NOT EXISTS DestinyUsers(
+    MembershipType     INT64,
+    MembershipID       TEXT,
+    DisplayName        TEXT,
+    BungieMembershipID TEXT,
+    PRIMARY KEY (MembershipType, MembershipID)
+);
+CREATE INDEX IF NOT EXISTS DestinyUsers_BungieMembershipID
+ON DestinyUsers (BungieMembershipID);
+`,
+	},
+	{
+		description: "Create DestinyCharacters.",
+		sql: `
+CREATE TABLE IF NOT EXISTS DestinyCharacters(
+    CharacterID           TEXT PRIMARY KEY,
+    ClassName             TEXT,
+    DestinyMembershipType INT64,
+    DestinyMembershipID   TEXT
+);
+CREATE INDEX IF NOT EXISTS DestinyCharacters_DestinyMembershipType_DestinyMembershipID
+ON DestinyCharacters (DestinyMembershipType, DestinyMembershipID);
+`,
+	},
+	{
+		description: "Create Unsubscriptions.",
+		sql: `
+CREATE TABLE IF NOT EXISTS Unsubscriptions(
+    BungieMembershipID TEXT,
+    VendorHash         INT64,
+    PRIMARY KEY (BungieMembershipID, VendorHash)
+);
+`,
+	},
+	{
+		description: "Create Emails.",
+		sql: `
+CREATE TABLE IF NOT EXISTS Emails(
+    BungieMembershipID TEXT PRIMARY KEY,
+    Address            TEXT,
+    Verified           BOOLEAN
+);
+`,
+	},
+	{
+		description: "Create Preferences.",
+		sql: `
+CREATE TABLE IF NOT EXISTS Preferences(
+    BungieMembershipID TEXT PRIMARY KEY,
+    Language           TEXT
+);
+`,
+	},
+	{
+		description: "Create Snapshots.",
+		sql: `
+CREATE TABLE Snapshots(
+    ID          INTEGER PRIMARY KEY,
+    CharacterID TEXT,
+    VendorHash  INT64,
+    TakenAt     DATETIME
+);
+CREATE INDEX Snapshots_CharacterID_VendorHash
+ON Snapshots (CharacterID, VendorHash);
+CREATE TABLE SnapshotItems(
+    SnapshotID INTEGER,
+    ItemHash   INT64,
+    Unlocked   BOOLEAN,
+    PRIMARY KEY (SnapshotID, ItemHash)
+);
+`,
+	},
+	{
+		description: "Add categories to snapshots.",
+		sql: `
+ALTER TABLE SnapshotItems ADD COLUMN Category TEXT NOT NULL DEFAULT '';
+ALTER TABLE SnapshotItems ADD COLUMN Position INT64 NOT NULL DEFAULT 0;
+`,
+	},
+	{
+		description: "Create Shares.",
+		sql: `
+CREATE TABLE Shares(
+    ID                 TEXT PRIMARY KEY,
+    BungieMembershipID TEXT,
+    Owner              TEXT,
+    CharacterID        TEXT,
+    ClassName          TEXT,
+    VendorHash         INT64,
+    CreatedAt          DATETIME
+);
+CREATE INDEX Shares_BungieMembershipID
+ON Shares (BungieMembershipID);
+`,
+	},
+	{
+		description: "Create Wishlists.",
+		sql: `
+CREATE TABLE Wishlists(
+    BungieMembershipID TEXT,
+    ItemHash           INT64,
+    Alerted            BOOLEAN NOT NULL DEFAULT 0,
+    PRIMARY KEY (BungieMembershipID, ItemHash)
+);
+`,
+	},
+}
@@ -0,0 +1,458 @@
+// Package storagetest checks that an implementation of db.Storage behaves the
+// way the rest of the code expects, so that every implementation can be held
+// to the same behaviour.  The checks write users whose membership IDs start
+// with "storagetest-", so run them against a scratch database.
+package storagetest
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/zhirsch/destinykioskstatus/db"
+	"github.com/zhirsch/oauth2"
+)
+
+type check struct {
+	name string
+	run  func(s db.Storage, membershipID db.BungieMembershipID) error
+}
+
+var checks = []check{
+	{"missing user", checkMissingUser},
+	{"user round trip", checkUserRoundTrip},
+	{"user update replaces characters", checkUserUpdate},
+	{"subscriptions", checkSubscriptions},
+	{"emails", checkEmails},
+	{"preferences", checkPreferences},
+	{"snapshots", checkSnapshots},
+	{"shares", checkShares},
+	{"wishlists", checkWishlists},
+}
+
+// Run runs every check against s, and returns an error for each check that
+// failed.
+func Run(s db.Storage) []error {
+	var errs []error
+	prefix := fmt.Sprintf("storagetest-%v", time.Now().UnixNano())
+	for i, c := range checks {
+		membershipID := db.BungieMembershipID(fmt.Sprintf("%v-%v", prefix, i))
+		if err := c.run(s, membershipID); err != nil {
+			errs = append(errs, fmt.Errorf("%v: %v", c.name, err))
+		}
+	}
+	return errs
+}
+
+func checkMissingUser(s db.Storage, membershipID db.BungieMembershipID) error {
+	if _, err := s.SelectBungieUser(membershipID); err == nil {
+		return fmt.Errorf("SelectBungieUser of a missing user didn't fail")
+	}
+	return nil
+}
+
+func newBungieUser(membershipID db.BungieMembershipID) *db.BungieUser {
+	return &db.BungieUser{
+		MembershipID: membershipID,
+		DisplayName:  "Guardian",
+		Token: &oauth2.Token{
+			AccessToken:  "access-" + string(membershipID),
+			RefreshToken: "refresh-" + string(membershipID),
+			// Whole seconds, since databases store times with different
+			// precision.
+			Expiry: time.Now().Add(time.Hour).Truncate(time.Second),
+		},
+		DestinyUsers: []*db.DestinyUser{
+			{
+				MembershipType: 2,
+				MembershipID:   db.DestinyMembershipID("d-" + string(membershipID)),
+				DisplayName:    "Guardian2",
+				DestinyCharacters: []*db.DestinyCharacter{
+					{CharacterID: db.DestinyCharacterID("c1-" + string(membershipID)), ClassName: "Hunter"},
+					{CharacterID: db.DestinyCharacterID("c2-" + string(membershipID)), ClassName: "Titan"},
+				},
+			},
+		},
+	}
+}
+
+func checkUserRoundTrip(s db.Storage, membershipID db.BungieMembershipID) error {
+	want := newBungieUser(membershipID)
+	if err := s.InsertBungieUser(want); err != nil {
+		return err
+	}
+	got, err := s.SelectBungieUser(membershipID)
+	if err != nil {
+		return err
+	}
+	return compareBungieUsers(got, want)
+}
+
+func checkUserUpdate(s db.Storage, membershipID db.BungieMembershipID) error {
+	user := newBungieUser(membershipID)
+	if err := s.InsertBungieUser(user); err != nil {
+		return err
+	}
+
+	// Rename the user, refresh the token, delete a character and create
+	// another.
+	user.DisplayName = "Renamed"
+	user.Token.AccessToken += "-new"
+	user.Token.Expiry = user.Token.Expiry.Add(time.Hour)
+	destinyUser := user.DestinyUsers[0]
+	destinyUser.DestinyCharacters = []*db.DestinyCharacter{
+		destinyUser.DestinyCharacters[1],
+		{CharacterID: db.DestinyCharacterID("c3-" + string(membershipID)), ClassName: "Warlock"},
+	}
+	if err := s.InsertBungieUser(user); err != nil {
+		return err
+	}
+	got, err := s.SelectBungieUser(membershipID)
+	if err != nil {
+		return err
+	}
+	if err := compareBungieUsers(got, user); err != nil {
+		return err
+	}
+
+	// Remove the Destiny user.
+	user.DestinyUsers = nil
+	if err := s.InsertBungieUser(user); err != nil {
+		return err
+	}
+	if got, err = s.SelectBungieUser(membershipID); err != nil {
+		return err
+	}
+	return compareBungieUsers(got, user)
+}
+
+func compareBungieUsers(got, want *db.BungieUser) error {
+	if got.MembershipID != want.MembershipID || got.DisplayName != want.DisplayName {
+		return fmt.Errorf("got user %v %q, want %v %q", got.MembershipID, got.DisplayName, want.MembershipID, want.DisplayName)
+	}
+	if got.Token.AccessToken != want.Token.AccessToken || got.Token.RefreshToken != want.Token.RefreshToken {
+		return fmt.Errorf("got tokens %q %q, want %q %q", got.Token.AccessToken, got.Token.RefreshToken, want.Token.AccessToken, want.Token.RefreshToken)
+	}
+	if !got.Token.Expiry.Equal(want.Token.Expiry) {
+		return fmt.Errorf("got expiry %v, want %v", got.Token.Expiry, want.Token.Expiry)
+	}
+	if len(got.DestinyUsers) != len(want.DestinyUsers) {
+		return fmt.Errorf("got %v destiny users, want %v", len(got.DestinyUsers), len(want.DestinyUsers))
+	}
+	for i, g := range got.DestinyUsers {
+		w := want.DestinyUsers[i]
+		if g.MembershipType != w.MembershipType || g.MembershipID != w.MembershipID || g.DisplayName != w.DisplayName {
+			return fmt.Errorf("got destiny user %+v, want %+v", g, w)
+		}
+		// The order of the characters isn't specified.
+		if g, w := characters(g), characters(w); g != w {
+			return fmt.Errorf("got characters %v, want %v", g, w)
+		}
+	}
+	return nil
+}
+
+func characters(destinyUser *db.DestinyUser) string {
+	var s []string
+	for _, c := range destinyUser.DestinyCharacters {
+		s = append(s, fmt.Sprintf("%v=%v", c.CharacterID, c.ClassName))
+	}
+	sort.Strings(s)
+	return fmt.Sprint(s)
+}
+
+func checkSubscriptions(s db.Storage, membershipID db.BungieMembershipID) error {
+	subscription, err := s.SelectSubscription(membershipID)
+	if err != nil {
+		return err
+	}
+	if !subscription.Subscribed(1) {
+		return fmt.Errorf("new user isn't subscribed")
+	}
+
+	// Unsubscribing twice is allowed.
+	for i := 0; i < 2; i++ {
+		if err := s.InsertUnsubscription(membershipID, 1); err != nil {
+			return err
+		}
+	}
+	if subscription, err = s.SelectSubscription(membershipID); err != nil {
+		return err
+	}
+	if subscription.Subscribed(1) || !subscription.Subscribed(2) || subscription.Unsubscribed {
+		return fmt.Errorf("after unsubscribing from vendor 1, got %+v", subscription)
+	}
+
+	if err := s.InsertUnsubscription(membershipID, db.WishlistAlerts); err != nil {
+		return err
+	}
+	if subscription, err = s.SelectSubscription(membershipID); err != nil {
+		return err
+	}
+	if subscription.Subscribed(db.WishlistAlerts) || !subscription.Subscribed(2) || subscription.Unsubscribed {
+		return fmt.Errorf("after unsubscribing from wishlist alerts, got %+v", subscription)
+	}
+
+	if err := s.InsertUnsubscription(membershipID, db.AllVendors); err != nil {
+		return err
+	}
+	if subscription, err = s.SelectSubscription(membershipID); err != nil {
+		return err
+	}
+	if !subscription.Unsubscribed || subscription.Subscribed(2) {
+		return fmt.Errorf("after unsubscribing from all vendors, got %+v", subscription)
+	}
+	return nil
+}
+
+func checkEmails(s db.Storage, membershipID db.BungieMembershipID) error {
+	if email, err := s.SelectEmail(membershipID); err != nil {
+		return err
+	} else if email != nil {
+		return fmt.Errorf("new user has email %+v", email)
+	}
+
+	for _, want := range []db.Email{
+		{Address: "guardian@example.com", Verified: false},
+		{Address: "guardian@example.com", Verified: true},
+		{Address: "other@example.com", Verified: false},
+	} {
+		if err := s.InsertEmail(membershipID, &want); err != nil {
+			return err
+		}
+		if got, err := s.SelectEmail(membershipID); err != nil {
+			return err
+		} else if got == nil || *got != want {
+			return fmt.Errorf("got email %+v, want %+v", got, want)
+		}
+	}
+	return nil
+}
+
+func checkPreferences(s db.Storage, membershipID db.BungieMembershipID) error {
+	if preferences, err := s.SelectPreferences(membershipID); err != nil {
+		return err
+	} else if *preferences != (db.Preferences{}) {
+		return fmt.Errorf("new user has preferences %+v", preferences)
+	}
+
+	for _, language := range []string{"fr", "de", ""} {
+		want := db.Preferences{Language: language}
+		if err := s.InsertPreferences(membershipID, &want); err != nil {
+			return err
+		}
+		if got, err := s.SelectPreferences(membershipID); err != nil {
+			return err
+		} else if *got != want {
+			return fmt.Errorf("got preferences %+v, want %+v", got, want)
+		}
+	}
+	return nil
+}
+
+func checkSnapshots(s db.Storage, membershipID db.BungieMembershipID) error {
+	characterID := db.DestinyCharacterID("c-" + string(membershipID))
+	if snapshot, err := s.SelectLatestSnapshot(characterID, 1); err != nil {
+		return err
+	} else if snapshot != nil {
+		return fmt.Errorf("new character has snapshot %+v", snapshot)
+	}
+
+	start := time.Now().Truncate(time.Second)
+	for i, c := range []struct {
+		items  map[uint32]bool
+		stored bool
+	}{
+		{map[uint32]bool{10: false, 11: true}, true},
+		{map[uint32]bool{10: false, 11: true}, false},
+		{map[uint32]bool{10: true, 11: true}, true},
+		{map[uint32]bool{10: true, 11: true, 12: false}, true},
+	} {
+		snapshot := &db.Snapshot{
+			CharacterID: characterID,
+			VendorHash:  1,
+			TakenAt:     start.Add(time.Duration(i) * time.Hour),
+			Items:       c.items,
+		}
+		if stored, err := s.InsertSnapshot(snapshot); err != nil {
+			return err
+		} else if stored != c.stored {
+			return fmt.Errorf("snapshot %v: got stored %v, want %v", i, stored, c.stored)
+		}
+	}
+
+	// Snapshots of other vendors are separate.
+	if _, err := s.InsertSnapshot(&db.Snapshot{CharacterID: characterID, VendorHash: 2, TakenAt: start, Items: map[uint32]bool{20: true}}); err != nil {
+		return err
+	}
+
+	latest, err := s.SelectLatestSnapshot(characterID, 1)
+	if err != nil {
+		return err
+	}
+	if latest == nil || !latest.TakenAt.Equal(start.Add(3*time.Hour)) || len(latest.Items) != 3 || latest.Unlocked() != 2 {
+		return fmt.Errorf("got latest snapshot %+v", latest)
+	}
+	if got := fmt.Sprint(latest.Categories); got != "[{ [10 11 12]}]" {
+		return fmt.Errorf("got categories %v of a snapshot without categories", got)
+	}
+
+	// The categories are kept in order, and are part of what makes a
+	// snapshot different.
+	categories := []db.SnapshotCategory{
+		{Title: "B", ItemHashes: []uint32{12, 11}},
+		{Title: "A", ItemHashes: []uint32{10}},
+	}
+	withCategories := &db.Snapshot{
+		CharacterID: characterID,
+		VendorHash:  1,
+		TakenAt:     start.Add(4 * time.Hour),
+		Items:       latest.Items,
+		Categories:  categories,
+	}
+	if stored, err := s.InsertSnapshot(withCategories); err != nil {
+		return err
+	} else if !stored {
+		return fmt.Errorf("snapshot with new categories wasn't stored")
+	}
+	if latest, err = s.SelectLatestSnapshot(characterID, 1); err != nil {
+		return err
+	}
+	if latest == nil {
+		return fmt.Errorf("snapshot with categories is missing")
+	}
+	if got, want := fmt.Sprint(latest.Categories), fmt.Sprint(categories); got != want {
+		return fmt.Errorf("got categories %v, want %v", got, want)
+	}
+
+	snapshots, err := s.SelectSnapshots(characterID, 1)
+	if err != nil {
+		return err
+	}
+	if len(snapshots) != 4 {
+		return fmt.Errorf("got %v snapshots, want 4", len(snapshots))
+	}
+	acquired := db.AcquiredAt(snapshots)
+	if !acquired[11].Equal(start) || !acquired[10].Equal(start.Add(2*time.Hour)) || len(acquired) != 2 {
+		return fmt.Errorf("got acquisition times %v", acquired)
+	}
+	return nil
+}
+
+func checkShares(s db.Storage, membershipID db.BungieMembershipID) error {
+	if shares, err := s.SelectShares(membershipID); err != nil {
+		return err
+	} else if len(shares) != 0 {
+		return fmt.Errorf("new user has shares %v", shares)
+	}
+
+	start := time.Now().Truncate(time.Second)
+	var want []db.Share
+	for i := 0; i < 2; i++ {
+		share := &db.Share{
+			MembershipID: membershipID,
+			Owner:        "Guardian",
+			CharacterID:  db.DestinyCharacterID(fmt.Sprintf("c%v-%v", i, membershipID)),
+			ClassName:    "Hunter",
+			VendorHash:   uint32(3000000000 + i),
+			CreatedAt:    start.Add(time.Duration(i) * time.Hour),
+		}
+		if err := s.InsertShare(share); err != nil {
+			return err
+		}
+		if share.ID == "" {
+			return fmt.Errorf("InsertShare didn't set the ID")
+		}
+		want = append(want, *share)
+	}
+	if want[0].ID == want[1].ID {
+		return fmt.Errorf("shares have the same ID %v", want[0].ID)
+	}
+
+	got, err := s.SelectShare(want[0].ID)
+	if err != nil {
+		return err
+	}
+	if got == nil || !sameShare(*got, want[0]) {
+		return fmt.Errorf("got share %+v, want %+v", got, want[0])
+	}
+
+	// Newest first.
+	shares, err := s.SelectShares(membershipID)
+	if err != nil {
+		return err
+	}
+	if len(shares) != 2 || !sameShare(*shares[0], want[1]) || !sameShare(*shares[1], want[0]) {
+		return fmt.Errorf("got shares %+v, want %+v", shares, want)
+	}
+
+	// Only the owner can revoke a share.
+	if err := s.DeleteShare("someone-else", want[0].ID); err != nil {
+		return err
+	}
+	if got, err := s.SelectShare(want[0].ID); err != nil {
+		return err
+	} else if got == nil {
+		return fmt.Errorf("another user revoked the share")
+	}
+	if err := s.DeleteShare(membershipID, want[0].ID); err != nil {
+		return err
+	}
+	if got, err := s.SelectShare(want[0].ID); err != nil {
+		return err
+	} else if got != nil {
+		return fmt.Errorf("revoked share still exists: %+v", got)
+	}
+	return nil
+}
+
+func checkWishlists(s db.Storage, membershipID db.BungieMembershipID) error {
+	wishlist, err := s.SelectWishlist(membershipID)
+	if err != nil {
+		return err
+	}
+	if len(wishlist.Alerted) != 0 {
+		return fmt.Errorf("new user has wishlist %v", wishlist.Alerted)
+	}
+
+	// Adding an item twice is allowed, and doesn't reset Alerted.  Item
+	// hashes above 2^31 must survive the round trip.
+	for _, itemHash := range []uint32{3000000000, 1, 3000000000} {
+		if err := s.InsertWishlistItem(membershipID, itemHash); err != nil {
+			return err
+		}
+	}
+	if err := s.UpdateWishlistAlerted(membershipID, 3000000000, true); err != nil {
+		return err
+	}
+	if err := s.InsertWishlistItem(membershipID, 3000000000); err != nil {
+		return err
+	}
+	if wishlist, err = s.SelectWishlist(membershipID); err != nil {
+		return err
+	}
+	want := map[uint32]bool{1: false, 3000000000: true}
+	if fmt.Sprint(wishlist.Alerted) != fmt.Sprint(want) {
+		return fmt.Errorf("got wishlist %v, want %v", wishlist.Alerted, want)
+	}
+	if hashes := wishlist.ItemHashes(); len(hashes) != 2 || hashes[0] != 1 || hashes[1] != 3000000000 {
+		return fmt.Errorf("got item hashes %v", hashes)
+	}
+
+	if err := s.DeleteWishlistItem(membershipID, 1); err != nil {
+		return err
+	}
+	if wishlist, err = s.SelectWishlist(membershipID); err != nil {
+		return err
+	}
+	if wishlist.Contains(1) || !wishlist.Contains(3000000000) {
+		return fmt.Errorf("after removing item 1, got %v", wishlist.Alerted)
+	}
+	return nil
+}
+
+func sameShare(a, b db.Share) bool {
+	createdAt := a.CreatedAt.Equal(b.CreatedAt)
+	a.CreatedAt, b.CreatedAt = time.Time{}, time.Time{}
+	return createdAt && a == b
+}
@@ -0,0 +1,59 @@
+package db
+
+const (
+	// AllVendors is the vendor hash used to unsubscribe from all
+	// notifications.
+	AllVendors uint32 = 0
+
+	// WishlistAlerts is the vendor hash used to unsubscribe from the alerts
+	// about items on the wishlist.  It isn't the hash of a real vendor.
+	WishlistAlerts uint32 = 1<<32 - 1
+)
+
+type Subscription struct {
+	Unsubscribed        bool
+	UnsubscribedVendors map[uint32]bool
+}
+
+// Subscribed returns whether the user should be notified about the vendor.
+func (s *Subscription) Subscribed(vendorHash uint32) bool {
+	return !s.Unsubscribed && !s.UnsubscribedVendors[vendorHash]
+}
+
+func (db *DB) SelectSubscription(membershipID BungieMembershipID) (*Subscription, error) {
+	subscription := &Subscription{
+		UnsubscribedVendors: make(map[uint32]bool),
+	}
+
+	rows, err := db.tables[tableUnsubscriptions].stmts[stmtSelect].Query(string(membershipID))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var vendorHash int64
+		if err := rows.Scan(&vendorHash); err != nil {
+			return nil, err
+		}
+		if uint32(vendorHash) == AllVendors {
+			subscription.Unsubscribed = true
+		} else {
+			subscription.UnsubscribedVendors[uint32(vendorHash)] = true
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return subscription, nil
+}
+
+// InsertUnsubscription unsubscribes the user from notifications about the
+// vendor, or from all notifications if vendorHash is AllVendors.
+func (db *DB) InsertUnsubscription(membershipID BungieMembershipID, vendorHash uint32) error {
+	_, err := db.tables[tableUnsubscriptions].stmts[stmtInsert].Exec(
+		string(membershipID),
+		int64(vendorHash),
+	)
+	return err
+}
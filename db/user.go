@@ -1,6 +1,8 @@
 package db
 
 import (
+	"database/sql"
+	"fmt"
 	"time"
 
 	"github.com/zhirsch/oauth2"
@@ -39,6 +41,14 @@ func (db *DB) SelectBungieUser(membershipID BungieMembershipID) (*BungieUser, er
 	if err := db.tables[tableBungieUsers].stmts[stmtSelect].QueryRow(string(membershipID)).Scan(&displayName, &accessToken, &refreshToken, &expiry); err != nil {
 		return nil, err
 	}
+	accessToken, err := db.keyring.decrypt(membershipID, accessToken)
+	if err != nil {
+		return nil, err
+	}
+	refreshToken, err = db.keyring.decrypt(membershipID, refreshToken)
+	if err != nil {
+		return nil, err
+	}
 	bungieUser := &BungieUser{
 		MembershipID: membershipID,
 		DisplayName:  displayName,
@@ -122,49 +132,81 @@ func (db *DB) SelectDestinyCharacters(destinyUser *DestinyUser) ([]*DestinyChara
 	return destinyCharacters, nil
 }
 
+// InsertBungieUser writes bungieUser, its DestinyUsers and their
+// DestinyCharacters in a single transaction.  The DestinyUsers and
+// DestinyCharacters that were previously stored for the BungieUser are
+// replaced, so characters that were deleted in-game are removed.
 func (db *DB) InsertBungieUser(bungieUser *BungieUser) error {
-	_, err := db.tables[tableBungieUsers].stmts[stmtInsert].Exec(
+	accessToken, err := db.keyring.encrypt(bungieUser.MembershipID, bungieUser.Token.AccessToken)
+	if err != nil {
+		return err
+	}
+	refreshToken, err := db.keyring.encrypt(bungieUser.MembershipID, bungieUser.Token.RefreshToken)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = db.txStmt(tx, tableBungieUsers, stmtInsert).Exec(
 		string(bungieUser.MembershipID),
 		bungieUser.DisplayName,
-		bungieUser.Token.AccessToken,
-		bungieUser.Token.RefreshToken,
+		accessToken,
+		refreshToken,
 		bungieUser.Token.Expiry,
 	)
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to insert bungie user %v: %v", bungieUser.MembershipID, err)
+	}
+
+	// Remove the old DestinyUsers and DestinyCharacters.  The characters must
+	// be deleted first, since they're found through the DestinyUsers.
+	if _, err := db.txStmt(tx, tableDestinyCharacters, stmtDelete).Exec(string(bungieUser.MembershipID)); err != nil {
+		return fmt.Errorf("failed to delete destiny characters of %v: %v", bungieUser.MembershipID, err)
+	}
+	if _, err := db.txStmt(tx, tableDestinyUsers, stmtDelete).Exec(string(bungieUser.MembershipID)); err != nil {
+		return fmt.Errorf("failed to delete destiny users of %v: %v", bungieUser.MembershipID, err)
 	}
+
 	for _, destinyUser := range bungieUser.DestinyUsers {
-		if err := db.InsertDestinyUser(bungieUser.MembershipID, destinyUser); err != nil {
+		if err := db.insertDestinyUser(tx, bungieUser.MembershipID, destinyUser); err != nil {
 			return err
 		}
 	}
-	return nil
+	return tx.Commit()
 }
 
-func (db *DB) InsertDestinyUser(bungieMembershipID BungieMembershipID, destinyUser *DestinyUser) error {
-	_, err := db.tables[tableDestinyUsers].stmts[stmtInsert].Exec(
+func (db *DB) insertDestinyUser(tx *sql.Tx, bungieMembershipID BungieMembershipID, destinyUser *DestinyUser) error {
+	_, err := db.txStmt(tx, tableDestinyUsers, stmtInsert).Exec(
 		int64(destinyUser.MembershipType),
 		string(destinyUser.MembershipID),
 		destinyUser.DisplayName,
 		string(bungieMembershipID),
 	)
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to insert destiny user %v: %v", destinyUser.MembershipID, err)
 	}
 	for _, destinyCharacter := range destinyUser.DestinyCharacters {
-		if err := db.InsertDestinyCharacter(destinyUser.MembershipType, destinyUser.MembershipID, destinyCharacter); err != nil {
+		if err := db.insertDestinyCharacter(tx, destinyUser.MembershipType, destinyUser.MembershipID, destinyCharacter); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func (db *DB) InsertDestinyCharacter(destinyMembershipType DestinyMembershipType, destinyMembershipID DestinyMembershipID, destinyCharacter *DestinyCharacter) error {
-	_, err := db.tables[tableDestinyCharacters].stmts[stmtInsert].Exec(
+func (db *DB) insertDestinyCharacter(tx *sql.Tx, destinyMembershipType DestinyMembershipType, destinyMembershipID DestinyMembershipID, destinyCharacter *DestinyCharacter) error {
+	_, err := db.txStmt(tx, tableDestinyCharacters, stmtInsert).Exec(
 		string(destinyCharacter.CharacterID),
 		destinyCharacter.ClassName,
 		int64(destinyMembershipType),
 		string(destinyMembershipID),
 	)
-	return err
+	if err != nil {
+		return fmt.Errorf("failed to insert destiny character %v: %v", destinyCharacter.CharacterID, err)
+	}
+	return nil
 }
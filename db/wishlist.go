@@ -0,0 +1,83 @@
+package db
+
+import (
+	"sort"
+)
+
+// A Wishlist is the items that a user wants to be alerted about when they're
+// for sale.
+type Wishlist struct {
+	// Alerted maps the hash of each item on the wishlist to whether the
+	// user has been alerted that it's for sale.
+	Alerted map[uint32]bool
+}
+
+// Contains returns whether the item is on the wishlist.
+func (w *Wishlist) Contains(itemHash uint32) bool {
+	_, ok := w.Alerted[itemHash]
+	return ok
+}
+
+// ItemHashes returns the hashes of the items on the wishlist, in order.
+func (w *Wishlist) ItemHashes() []uint32 {
+	var itemHashes []uint32
+	for itemHash := range w.Alerted {
+		itemHashes = append(itemHashes, itemHash)
+	}
+	sort.Slice(itemHashes, func(i, j int) bool { return itemHashes[i] < itemHashes[j] })
+	return itemHashes
+}
+
+func (db *DB) SelectWishlist(membershipID BungieMembershipID) (*Wishlist, error) {
+	wishlist := &Wishlist{
+		Alerted: make(map[uint32]bool),
+	}
+
+	rows, err := db.tables[tableWishlists].stmts[stmtSelect].Query(string(membershipID))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var itemHash int64
+		var alerted bool
+		if err := rows.Scan(&itemHash, &alerted); err != nil {
+			return nil, err
+		}
+		wishlist.Alerted[uint32(itemHash)] = alerted
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return wishlist, nil
+}
+
+// InsertWishlistItem adds the item to the user's wishlist.  Adding an item
+// that's already on it does nothing.
+func (db *DB) InsertWishlistItem(membershipID BungieMembershipID, itemHash uint32) error {
+	_, err := db.tables[tableWishlists].stmts[stmtInsert].Exec(
+		string(membershipID),
+		int64(itemHash),
+	)
+	return err
+}
+
+func (db *DB) DeleteWishlistItem(membershipID BungieMembershipID, itemHash uint32) error {
+	_, err := db.tables[tableWishlists].stmts[stmtDelete].Exec(
+		string(membershipID),
+		int64(itemHash),
+	)
+	return err
+}
+
+// UpdateWishlistAlerted records whether the user has been alerted that the
+// item is for sale, so that they're only alerted once each time it's sold.
+func (db *DB) UpdateWishlistAlerted(membershipID BungieMembershipID, itemHash uint32, alerted bool) error {
+	_, err := db.tables[tableWishlists].stmts[stmtUpdate].Exec(
+		alerted,
+		string(membershipID),
+		int64(itemHash),
+	)
+	return err
+}
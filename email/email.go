@@ -0,0 +1,118 @@
+// Package email builds the data that is rendered into the notification emails.
+package email
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/zhirsch/destinykioskstatus/db"
+	"github.com/zhirsch/destinykioskstatus/kiosk"
+	"github.com/zhirsch/destinykioskstatus/sign"
+)
+
+const (
+	unsubscribePurpose = "unsubscribe"
+	verifyPurpose      = "verify"
+
+	verifyExpiry = 24 * time.Hour
+)
+
+type Kiosk struct {
+	kiosk.Data
+	UnsubscribeURL string
+}
+
+type Data struct {
+	Kiosks         []Kiosk
+	UnsubscribeURL string
+}
+
+func (d Data) MissingAndForSale() bool {
+	for _, k := range d.Kiosks {
+		if k.MissingAndForSale() {
+			return true
+		}
+	}
+	return false
+}
+
+// Links creates the signed links that are embedded in the emails.
+type Links struct {
+	BaseURL string
+	Signer  *sign.Signer
+}
+
+// Unsubscribe returns a link that unsubscribes the user from notifications
+// about the vendor, from all notifications if vendorHash is db.AllVendors, or
+// from wishlist alerts if it's db.WishlistAlerts.
+func (l Links) Unsubscribe(membershipID db.BungieMembershipID, vendorHash uint32) string {
+	v := url.Values{}
+	v.Set("m", string(membershipID))
+	v.Set("v", strconv.FormatUint(uint64(vendorHash), 10))
+	q := url.Values{}
+	q.Set("t", l.Signer.Sign(unsubscribePurpose, v.Encode(), time.Time{}))
+	return l.BaseURL + "/unsubscribe?" + q.Encode()
+}
+
+// ParseUnsubscribeToken verifies a token created by Links.Unsubscribe and
+// returns the user and vendor that it is for.
+func ParseUnsubscribeToken(signer *sign.Signer, token string) (db.BungieMembershipID, uint32, error) {
+	value, err := signer.Verify(unsubscribePurpose, token, time.Now())
+	if err != nil {
+		return "", 0, err
+	}
+	v, err := url.ParseQuery(value)
+	if err != nil {
+		return "", 0, err
+	}
+	vendorHash, err := strconv.ParseUint(v.Get("v"), 10, 32)
+	if err != nil {
+		return "", 0, fmt.Errorf("bad vendor hash in unsubscribe token: %v", err)
+	}
+	return db.BungieMembershipID(v.Get("m")), uint32(vendorHash), nil
+}
+
+// NewData returns the data for the email sent to the user, leaving out the
+// vendors that the user has unsubscribed from.
+func NewData(kiosks []kiosk.Data, membershipID db.BungieMembershipID, subscription *db.Subscription, links Links) Data {
+	data := Data{
+		UnsubscribeURL: links.Unsubscribe(membershipID, db.AllVendors),
+	}
+	for _, k := range kiosks {
+		if !subscription.Subscribed(k.VendorHash) {
+			continue
+		}
+		data.Kiosks = append(data.Kiosks, Kiosk{
+			Data:           k,
+			UnsubscribeURL: links.Unsubscribe(membershipID, k.VendorHash),
+		})
+	}
+	return data
+}
+
+// Verify returns a link that marks the address as verified for the user.  The
+// link expires after verifyExpiry.
+func (l Links) Verify(membershipID db.BungieMembershipID, address string) string {
+	v := url.Values{}
+	v.Set("m", string(membershipID))
+	v.Set("e", address)
+	q := url.Values{}
+	q.Set("t", l.Signer.Sign(verifyPurpose, v.Encode(), time.Now().Add(verifyExpiry)))
+	return l.BaseURL + "/verify?" + q.Encode()
+}
+
+// ParseVerifyToken verifies a token created by Links.Verify and returns the
+// user and address that it is for.
+func ParseVerifyToken(signer *sign.Signer, token string) (db.BungieMembershipID, string, error) {
+	value, err := signer.Verify(verifyPurpose, token, time.Now())
+	if err != nil {
+		return "", "", err
+	}
+	v, err := url.ParseQuery(value)
+	if err != nil {
+		return "", "", err
+	}
+	return db.BungieMembershipID(v.Get("m")), v.Get("e"), nil
+}
@@ -0,0 +1,55 @@
+package email
+
+import (
+	"fmt"
+
+	sendgrid "github.com/sendgrid/sendgrid-go"
+	"github.com/sendgrid/sendgrid-go/helpers/mail"
+)
+
+const (
+	sendGridEndpoint = "/v3/mail/send"
+)
+
+// A Message is an email with both a plain text and an HTML part.
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+	Headers map[string]string
+}
+
+// Sender sends emails through SendGrid.
+type Sender struct {
+	APIKey   string
+	Host     string
+	FromName string
+	FromAddr string
+}
+
+func (s *Sender) Send(msg *Message) error {
+	from := mail.NewEmail(s.FromName, s.FromAddr)
+	to := mail.NewEmail("", msg.To)
+
+	// The text/plain part must come before the text/html part.
+	textContent := mail.NewContent("text/plain", msg.Text)
+	htmlContent := mail.NewContent("text/html", msg.HTML)
+
+	m := mail.NewV3MailInit(from, msg.Subject, to, textContent, htmlContent)
+	for k, v := range msg.Headers {
+		m.SetHeader(k, v)
+	}
+
+	request := sendgrid.GetRequest(s.APIKey, sendGridEndpoint, s.Host)
+	request.Method = "POST"
+	request.Body = mail.GetRequestBody(m)
+	response, err := sendgrid.API(request)
+	if err != nil {
+		return err
+	}
+	if response.StatusCode/100 != 2 {
+		return fmt.Errorf("bad response from SendGrid: %v: %v", response.StatusCode, response.Body)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package email
+
+import (
+	"bytes"
+	htmltemplate "html/template"
+	"text/template"
+)
+
+var (
+	verifyTextTemplate = template.Must(template.New("verify").Parse(`Confirm that you want to receive Destiny Kiosk Status emails at this address by opening this link:
+
+{{.}}
+
+If you didn't ask for this, you can ignore this email.
+`))
+	verifyHTMLTemplate = htmltemplate.Must(htmltemplate.New("verify").Parse(`<html>
+  <body>
+    <p>Confirm that you want to receive Destiny Kiosk Status emails at this address:</p>
+    <p><a href="{{.}}">Verify my email</a></p>
+    <p>If you didn't ask for this, you can ignore this email.</p>
+  </body>
+</html>
+`))
+)
+
+// NewVerifyMessage returns the email that asks the user to open link to verify
+// their address.
+func NewVerifyMessage(address, link string) (*Message, error) {
+	text := new(bytes.Buffer)
+	if err := verifyTextTemplate.Execute(text, link); err != nil {
+		return nil, err
+	}
+	html := new(bytes.Buffer)
+	if err := verifyHTMLTemplate.Execute(html, link); err != nil {
+		return nil, err
+	}
+	return &Message{
+		To:      address,
+		Subject: "Verify your email for Destiny Kiosk Status",
+		Text:    text.String(),
+		HTML:    html.String(),
+	}, nil
+}
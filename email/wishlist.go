@@ -0,0 +1,93 @@
+package email
+
+import (
+	"bytes"
+	htmltemplate "html/template"
+	"text/template"
+
+	"github.com/zhirsch/destinykioskstatus/db"
+	"github.com/zhirsch/destinykioskstatus/kiosk"
+)
+
+var (
+	wishlistTextTemplate = template.Must(template.New("wishlist").Parse(`These items on your wishlist are for sale:
+{{range .Items}}
+  {{.Item.Name}} ({{.Vendor}})
+{{- end}}
+
+See your wishlist: {{.WishlistURL}}
+
+Stop wishlist alerts: {{.UnsubscribeURL}}
+`))
+	wishlistHTMLTemplate = htmltemplate.Must(htmltemplate.New("wishlist").Parse(`<html>
+  <body>
+    <p>These items on your wishlist are for sale:</p>
+    <table>
+      {{range .Items}}
+      <tr>
+        <td><img src="{{.Item.Icon}}" alt="" width="48" height="48" /></td>
+        <td>{{.Item.Name}}</td>
+        <td>{{.Vendor}}</td>
+      </tr>
+      {{end}}
+    </table>
+    <p><a href="{{.WishlistURL}}">See your wishlist</a></p>
+    <p><a href="{{.UnsubscribeURL}}">Stop wishlist alerts</a></p>
+  </body>
+</html>
+`))
+)
+
+// A WishlistAlert tells the user that items on their wishlist that they're
+// missing are for sale.
+type WishlistAlert struct {
+	Items          []kiosk.SearchResult
+	WishlistURL    string
+	UnsubscribeURL string
+}
+
+// NewWishlistAlert returns the alert for the wishlisted items, as returned by
+// kiosk.Wishlisted, that are missing and for sale and that the user hasn't
+// been alerted about yet.
+func NewWishlistAlert(wishlisted []kiosk.SearchResult, wishlist *db.Wishlist, membershipID db.BungieMembershipID, links Links) WishlistAlert {
+	alert := WishlistAlert{
+		WishlistURL:    links.BaseURL + "/wishlist",
+		UnsubscribeURL: links.Unsubscribe(membershipID, db.WishlistAlerts),
+	}
+	seen := make(map[uint32]bool)
+	for _, result := range wishlisted {
+		item := result.Item
+		if !item.Missing || !item.ForSale || wishlist.Alerted[item.Hash] || seen[item.Hash] {
+			continue
+		}
+		seen[item.Hash] = true
+		alert.Items = append(alert.Items, result)
+	}
+	return alert
+}
+
+// Message returns the email for the alert.
+func (a WishlistAlert) Message(address string) (*Message, error) {
+	text := new(bytes.Buffer)
+	if err := wishlistTextTemplate.Execute(text, a); err != nil {
+		return nil, err
+	}
+	html := new(bytes.Buffer)
+	if err := wishlistHTMLTemplate.Execute(html, a); err != nil {
+		return nil, err
+	}
+	subject := "Items on your wishlist are for sale"
+	if len(a.Items) == 1 {
+		subject = a.Items[0].Item.Name + " is for sale"
+	}
+	return &Message{
+		To:      address,
+		Subject: subject,
+		Text:    text.String(),
+		HTML:    html.String(),
+		Headers: map[string]string{
+			"List-Unsubscribe":      "<" + a.UnsubscribeURL + ">",
+			"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+		},
+	}, nil
+}
@@ -0,0 +1,64 @@
+package email
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/zhirsch/destinykioskstatus/db"
+	"github.com/zhirsch/destinykioskstatus/kiosk"
+	"github.com/zhirsch/destinykioskstatus/sign"
+)
+
+func TestNewWishlistAlert(t *testing.T) {
+	signer, err := sign.NewSigner([]byte("0123456789abcdef0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	links := Links{BaseURL: "https://example.com", Signer: signer}
+
+	// The kiosks of two characters.  Item 4 is only sold to the second.
+	kiosks := []kiosk.Data{
+		{Title: "Armor", Categories: []kiosk.Category{{Items: []kiosk.Item{
+			{Hash: 1, Name: "Owned"},
+			{Hash: 2, Name: "For sale", Missing: true, ForSale: true},
+			{Hash: 3, Name: "Already alerted", Missing: true, ForSale: true},
+			{Hash: 5, Name: "Not for sale", Missing: true},
+			{Hash: 6, Name: "Not wishlisted", Missing: true, ForSale: true},
+		}}}},
+		{Title: "Armor", Categories: []kiosk.Category{{Items: []kiosk.Item{
+			{Hash: 2, Name: "For sale", Missing: true, ForSale: true},
+			{Hash: 4, Name: "Class item", Missing: true, ForSale: true},
+		}}}},
+	}
+	wishlist := &db.Wishlist{Alerted: map[uint32]bool{1: false, 2: false, 3: true, 4: false, 5: false}}
+
+	alert := NewWishlistAlert(kiosk.Wishlisted(kiosks, wishlist), wishlist, "m", links)
+	var got []uint32
+	for _, result := range alert.Items {
+		got = append(got, result.Item.Hash)
+	}
+	if len(got) != 2 || got[0] != 2 || got[1] != 4 {
+		t.Errorf("alerted about items %v, want [2 4]", got)
+	}
+
+	// The link only stops the wishlist alerts, not the other emails.
+	u, err := url.Parse(alert.UnsubscribeURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	membershipID, vendorHash, err := ParseUnsubscribeToken(signer, u.Query().Get("t"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if membershipID != "m" || vendorHash != db.WishlistAlerts {
+		t.Errorf("unsubscribe link is for %v and %v, want m and %v", membershipID, vendorHash, db.WishlistAlerts)
+	}
+
+	msg, err := alert.Message("guardian@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.To != "guardian@example.com" || msg.Subject != "Items on your wishlist are for sale" {
+		t.Errorf("got message to %v with subject %q", msg.To, msg.Subject)
+	}
+}
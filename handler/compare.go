@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"html/template"
+	"net/http"
+	"net/url"
+
+	"github.com/zhirsch/destinykioskstatus/db"
+	"github.com/zhirsch/destinykioskstatus/kiosk"
+)
+
+var compareTemplate = template.Must(template.New("compare").Parse(`<!DOCTYPE html>
+<html>
+  <head>
+    <meta charset="UTF-8">
+    <title>{{.Title}}</title>
+    <style>
+      @import url('https://fonts.googleapis.com/css?family=Nunito:300');
+      body {
+        font-family: "Nunito";
+      }
+      td, th {
+        padding: 0 8px;
+        text-align: left;
+      }
+      td > img {
+        width: 48px;
+        height: 48px;
+      }
+      tr.owned {
+        opacity: 0.4;
+      }
+      tr.differs {
+        background: #fff3c4;
+      }
+      .missing {
+        font-weight: bold;
+      }
+      .stale {
+        font-style: italic;
+      }
+    </style>
+  </head>
+  <body>
+    <div>
+      {{.User}}
+      &mdash;
+      <a href="{{.BackURL}}">Back to {{.Title}}</a>
+    </div>
+    {{if .Note}}<p>{{.Note}}</p>{{end}}
+    {{if .StaleManifest}}
+    <p class="stale">Some items aren't in the manifest yet, so they're shown as unknown.</p>
+    {{end}}
+    <table>
+      {{range .Categories}}
+      <tr>
+        <th colspan="2"><h1>{{.Title}}</h1></th>
+        {{range $.Columns}}<th>{{.}}</th>{{end}}
+      </tr>
+      {{range .Rows}}
+      <tr class="{{if not .MissingAny}}owned{{else if .Differs}}differs{{end}}">
+        <td><img src="{{.Item.Icon}}" alt="" /></td>
+        <td>{{.Item.Name}}</td>
+        {{range .Statuses}}
+        <td>
+          {{if not .Sold}}&mdash;{{else if .Missing}}<span class="missing">Missing</span>{{if .ForSale}} <img src="/media/dollar.png" alt="For sale" />{{end}}{{else}}Owned{{end}}
+        </td>
+        {{end}}
+      </tr>
+      {{end}}
+      {{end}}
+    </table>
+  </body>
+</html>
+`))
+
+type compareData struct {
+	kiosk.Comparison
+	BackURL string
+	Note    string
+}
+
+// serveComparison shows the kiosk's items for every character side by side.
+func (h VendorHandler) serveComparison(bungieUser *db.BungieUser, destinyUser *db.DestinyUser, w http.ResponseWriter, r *http.Request) {
+	manifest := h.Server.Manifests.Get(userLocale(h.Server, bungieUser, r))
+	kioskData := kiosk.FetchKioskStatusForCharacters(bungieUser, destinyUser, h.VendorHash, h.Server.API, manifest)
+	saveSnapshots(h.Server, kioskData...)
+
+	data := compareData{
+		Comparison: kiosk.CompareCharacters(destinyUser, kioskData),
+		BackURL:    compareURL(*r.URL, false),
+	}
+	if wantsJSON(r) {
+		writeJSON(w, data.Comparison)
+		return
+	}
+	if err := compareTemplate.Execute(w, data); err != nil {
+		panic(err)
+	}
+}
+
+// compareURL returns the URL of the kiosk page with comparison mode turned on
+// or off.
+func compareURL(u url.URL, compare bool) string {
+	q := u.Query()
+	if compare {
+		q.Set("compare", "1")
+	} else {
+		q.Del("compare")
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
@@ -0,0 +1,33 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/zhirsch/destinykioskstatus/db"
+	"github.com/zhirsch/destinykioskstatus/server"
+)
+
+const (
+	// formPurpose signs the tokens in forms that change the user's data, so
+	// that other sites can't submit them for the user.
+	formPurpose = "form"
+
+	formTokenExpiry = 24 * time.Hour
+)
+
+// formToken returns the token to put in the "token" field of the user's forms.
+func formToken(s *server.Server, bungieUser *db.BungieUser) string {
+	return s.Signer.Sign(formPurpose, string(bungieUser.MembershipID), time.Now().Add(formTokenExpiry))
+}
+
+// checkFormToken returns whether the submitted form has a token for the user,
+// and responds with an error if it doesn't.
+func checkFormToken(s *server.Server, bungieUser *db.BungieUser, w http.ResponseWriter, r *http.Request) bool {
+	value, err := s.Signer.Verify(formPurpose, r.PostFormValue("token"), time.Now())
+	if err != nil || value != string(bungieUser.MembershipID) {
+		http.Error(w, "The form has expired.  Go back, reload the page and try again.", http.StatusForbidden)
+		return false
+	}
+	return true
+}
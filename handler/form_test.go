@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/zhirsch/destinykioskstatus/db"
+	"github.com/zhirsch/destinykioskstatus/server"
+	"github.com/zhirsch/destinykioskstatus/sign"
+)
+
+func TestFormToken(t *testing.T) {
+	signer, err := sign.NewSigner([]byte("0123456789abcdef0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &server.Server{Signer: signer}
+	user := &db.BungieUser{MembershipID: "1"}
+	other := &db.BungieUser{MembershipID: "2"}
+
+	for _, c := range []struct {
+		name  string
+		token string
+		want  bool
+	}{
+		{"own token", formToken(s, user), true},
+		{"no token", "", false},
+		{"other user's token", formToken(s, other), false},
+		{"garbage", "not a token", false},
+	} {
+		form := url.Values{"token": {c.token}}
+		r := httptest.NewRequest("POST", "/share", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		if got := checkFormToken(s, user, w, r); got != c.want {
+			t.Errorf("%v: got %v, want %v", c.name, got, c.want)
+		}
+		if !c.want && w.Code != http.StatusForbidden {
+			t.Errorf("%v: got status %v, want %v", c.name, w.Code, http.StatusForbidden)
+		}
+	}
+
+	// The token must be in the body, not in the URL, so that it isn't
+	// leaked in links.
+	r := httptest.NewRequest("POST", "/share?token="+url.QueryEscape(formToken(s, user)), nil)
+	if checkFormToken(s, user, httptest.NewRecorder(), r) {
+		t.Error("accepted a token in the URL")
+	}
+}
@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"html/template"
+	"net/http"
+	"net/url"
+
+	"github.com/zhirsch/destinykioskstatus/db"
+	"github.com/zhirsch/destinykioskstatus/kiosk"
+	"github.com/zhirsch/destinykioskstatus/server"
+)
+
+var historyTemplate = template.Must(template.New("history").Parse(`<!DOCTYPE html>
+<html>
+  <head>
+    <meta charset="UTF-8">
+    <title>History</title>
+    <style>
+      @import url('https://fonts.googleapis.com/css?family=Nunito:300');
+      body {
+        font-family: "Nunito";
+      }
+      td, th {
+        padding: 0 8px;
+        text-align: left;
+      }
+      td.count {
+        text-align: right;
+      }
+      progress {
+        width: 240px;
+      }
+    </style>
+    <script>
+      function switchURL(url) {
+        document.location = url;
+      }
+    </script>
+  </head>
+  <body>
+    <div>
+      {{.User}}
+      &mdash;
+      <a href="/overview?c={{.CurrentCharacter}}">Overview</a>
+      &mdash;
+      <select onchange="switchURL(this.value)">
+        {{range .Characters}}
+        <option value="{{.URL}}" {{if .Current}}selected="selected"{{end}}>{{.Class}}</option>
+        {{end}}
+      </select>
+    </div>
+    {{range .Kiosks}}
+    <h1><a href="{{.URL}}">{{.Title}}</a></h1>
+    {{if .Progress}}
+    <table>
+      {{range .Progress}}
+      <tr>
+        <td>{{.TakenAt.Format "January 2, 2006 15:04"}}</td>
+        <td class="count">{{.Owned}} of {{.Total}}</td>
+        <td><progress max="100" value="{{.Percent}}">{{.Percent}}%</progress> {{.Percent}}%</td>
+      </tr>
+      {{end}}
+    </table>
+    {{else}}
+    <p>Nothing has been recorded yet.</p>
+    {{end}}
+    {{end}}
+  </body>
+</html>
+`))
+
+type historyData struct {
+	User             string
+	Characters       []Character
+	CurrentCharacter string
+	Kiosks           []historyKiosk
+}
+
+type historyKiosk struct {
+	kiosk.History
+	URL string
+}
+
+// HistoryHandler shows how a character's collection of each kiosk grew, from
+// the snapshots recorded whenever the kiosks were looked at.  It doesn't fetch
+// anything from Bungie.
+type HistoryHandler struct {
+	Server *server.Server
+
+	// VendorPaths are the paths of the kiosk pages, by vendor hash.
+	VendorPaths map[uint32]string
+}
+
+func (h HistoryHandler) ServeHTTP(bungieUser *db.BungieUser, w http.ResponseWriter, r *http.Request) {
+	// TODO: Support multiple DestinyUsers on the same BungieUser.
+	destinyUser := bungieUser.DestinyUsers[0]
+
+	characterID := db.DestinyCharacterID(r.URL.Query().Get("c"))
+	if characterID == "" {
+		http.Redirect(w, r, characterURL(*r.URL, destinyUser.DestinyCharacters[0]), http.StatusFound)
+		return
+	}
+	// The snapshots aren't fetched with the user's token, so make sure that
+	// the character is the user's own.
+	if !ownsCharacter(destinyUser, characterID) {
+		http.Error(w, "bad character", http.StatusBadRequest)
+		return
+	}
+
+	manifest := h.Server.Manifests.Get(userLocale(h.Server, bungieUser, r))
+	data := historyData{
+		User:             destinyUser.DisplayName,
+		Characters:       characters(*r.URL, destinyUser, characterID),
+		CurrentCharacter: string(characterID),
+	}
+	for _, vendorHash := range kiosk.VendorHashes {
+		snapshots, err := h.Server.DB.SelectSnapshots(characterID, vendorHash)
+		if err != nil {
+			panic(err)
+		}
+		data.Kiosks = append(data.Kiosks, historyKiosk{
+			History: kiosk.NewHistory(vendorHash, kiosk.VendorName(vendorHash, manifest), snapshots),
+			URL:     h.VendorPaths[vendorHash] + "?c=" + url.QueryEscape(string(characterID)),
+		})
+	}
+
+	if wantsJSON(r) {
+		writeJSON(w, data)
+		return
+	}
+	if err := historyTemplate.Execute(w, data); err != nil {
+		panic(err)
+	}
+}
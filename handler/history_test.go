@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/zhirsch/destinykioskstatus/db"
+	"github.com/zhirsch/destinykioskstatus/server"
+)
+
+func TestHistoryRejectsOtherCharacters(t *testing.T) {
+	// The server has no database, so the handler fails if it gets past the
+	// character check.
+	h := HistoryHandler{Server: &server.Server{}}
+	user := &db.BungieUser{
+		MembershipID: "1",
+		DestinyUsers: []*db.DestinyUser{{
+			DestinyCharacters: []*db.DestinyCharacter{{CharacterID: "mine", ClassName: "Hunter"}},
+		}},
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(user, w, httptest.NewRequest("GET", "/history?c=theirs", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("got status %v for another player's character, want %v", w.Code, http.StatusBadRequest)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(user, w, httptest.NewRequest("GET", "/history", nil))
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "/history?c=mine" {
+		t.Errorf("got status %v and location %q without a character, want a redirect to /history?c=mine", w.Code, w.Header().Get("Location"))
+	}
+}
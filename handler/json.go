@@ -0,0 +1,18 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// wantsJSON returns whether the response should be JSON instead of HTML.
+func wantsJSON(r *http.Request) bool {
+	return r.URL.Query().Get("format") == "json"
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		panic(err)
+	}
+}
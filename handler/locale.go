@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/zhirsch/destinykioskstatus/db"
+	"github.com/zhirsch/destinykioskstatus/server"
+)
+
+// userLocale returns the locale to show the page in: the user's preferred
+// language if they've set one, otherwise the browser's.
+func userLocale(s *server.Server, bungieUser *db.BungieUser, r *http.Request) string {
+	preferences, err := s.DB.SelectPreferences(bungieUser.MembershipID)
+	if err != nil {
+		panic(err)
+	}
+	tags := parseAcceptLanguage(r.Header.Get("Accept-Language"))
+	if preferences.Language != "" {
+		tags = append([]string{preferences.Language}, tags...)
+	}
+	return s.Manifests.Match(tags...)
+}
+
+// parseAcceptLanguage returns the language tags in an Accept-Language header,
+// most preferred first.
+func parseAcceptLanguage(header string) []string {
+	type weightedTag struct {
+		tag string
+		q   float64
+	}
+	var weighted []weightedTag
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		tag := strings.TrimSpace(fields[0])
+		if tag == "" || tag == "*" {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if q > 0 {
+			weighted = append(weighted, weightedTag{tag, q})
+		}
+	}
+	sort.SliceStable(weighted, func(i, j int) bool { return weighted[i].q > weighted[j].q })
+
+	var tags []string
+	for _, w := range weighted {
+		tags = append(tags, w.tag)
+	}
+	return tags
+}
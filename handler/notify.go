@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/zhirsch/destinykioskstatus/db"
+	"github.com/zhirsch/destinykioskstatus/email"
+	"github.com/zhirsch/destinykioskstatus/kiosk"
+	"github.com/zhirsch/destinykioskstatus/server"
+)
+
+// NotificationPreviewHandler renders the notification email that would be
+// sent to the user, using live data.
+type NotificationPreviewHandler struct {
+	Server *server.Server
+}
+
+func (h NotificationPreviewHandler) ServeHTTP(bungieUser *db.BungieUser, w http.ResponseWriter, r *http.Request) {
+	// TODO: Support multiple DestinyUsers on the same BungieUser.
+	destinyUser := bungieUser.DestinyUsers[0]
+
+	subscription, err := h.Server.DB.SelectSubscription(bungieUser.MembershipID)
+	if err != nil {
+		panic(err)
+	}
+
+	// Emails use the user's preferred language, not the browser's.
+	preferences, err := h.Server.DB.SelectPreferences(bungieUser.MembershipID)
+	if err != nil {
+		panic(err)
+	}
+	manifest := h.Server.Manifests.Get(h.Server.Manifests.Match(preferences.Language))
+
+	links := email.Links{BaseURL: "https://" + r.Host, Signer: h.Server.Signer}
+	data := email.NewData(kiosk.FetchAllKioskStatus(bungieUser, destinyUser, h.Server.API, manifest), bungieUser.MembershipID, subscription, links)
+	if err := h.Server.NotifyTemplate.Execute(w, data); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,146 @@
+package handler
+
+import (
+	"html/template"
+	"net/http"
+	"net/url"
+
+	"github.com/zhirsch/destinykioskstatus/db"
+	"github.com/zhirsch/destinykioskstatus/kiosk"
+	"github.com/zhirsch/destinykioskstatus/server"
+)
+
+var overviewTemplate = template.Must(template.New("overview").Parse(`<!DOCTYPE html>
+<html>
+  <head>
+    <meta charset="UTF-8">
+    <title>Overview</title>
+    <style>
+      @import url('https://fonts.googleapis.com/css?family=Nunito:300');
+      body {
+        font-family: "Nunito";
+      }
+      td, th {
+        padding: 0 8px;
+        text-align: left;
+      }
+      td.count {
+        text-align: right;
+      }
+      tr.vendor > td {
+        font-weight: bold;
+        padding-top: 8px;
+      }
+      progress {
+        width: 120px;
+      }
+    </style>
+    <script>
+      function switchURL(url) {
+        document.location = url;
+      }
+    </script>
+  </head>
+  <body>
+    <div>
+      {{.User}}
+      &mdash;
+      <select onchange="switchURL(this.value)">
+        {{range .Characters}}
+        <option value="{{.URL}}" {{if .Current}}selected="selected"{{end}}>{{.Class}}</option>
+        {{end}}
+      </select>
+    </div>
+    <p>
+      {{with .Total}}{{.Owned}} of {{.Total}} items ({{.Percent}}%){{end}}
+      &mdash;
+      <a href="/history?c={{.CurrentCharacter}}">History</a>
+    </p>
+    <table>
+      <tr>
+        <th></th>
+        <th>Owned</th>
+        <th>Total</th>
+        <th>Missing and for sale</th>
+        <th></th>
+      </tr>
+      {{range .Kiosks}}
+      <tr class="vendor">
+        <td><a href="{{.URL}}">{{.Title}}</a></td>
+        <td class="count">{{.Owned}}</td>
+        <td class="count">{{.Total}}</td>
+        <td class="count">{{.MissingAndForSale}}</td>
+        <td><progress max="100" value="{{.Percent}}">{{.Percent}}%</progress> {{.Percent}}%</td>
+      </tr>
+      {{range .Categories}}
+      <tr>
+        <td>{{.Title}}</td>
+        <td class="count">{{.Owned}}</td>
+        <td class="count">{{.Total}}</td>
+        <td class="count">{{.MissingAndForSale}}</td>
+        <td><progress max="100" value="{{.Percent}}">{{.Percent}}%</progress> {{.Percent}}%</td>
+      </tr>
+      {{end}}
+      {{end}}
+    </table>
+  </body>
+</html>
+`))
+
+type overviewData struct {
+	User             string
+	Characters       []Character
+	CurrentCharacter string
+	Total            kiosk.Stats
+	Kiosks           []overviewKiosk
+}
+
+type overviewKiosk struct {
+	kiosk.Summary
+	URL string
+}
+
+// OverviewHandler shows the completion statistics of every kiosk for a
+// character.
+type OverviewHandler struct {
+	Server *server.Server
+
+	// VendorPaths are the paths of the kiosk pages, by vendor hash.
+	VendorPaths map[uint32]string
+}
+
+func (h OverviewHandler) ServeHTTP(bungieUser *db.BungieUser, w http.ResponseWriter, r *http.Request) {
+	// TODO: Support multiple DestinyUsers on the same BungieUser.
+	destinyUser := bungieUser.DestinyUsers[0]
+
+	characterID := db.DestinyCharacterID(r.URL.Query().Get("c"))
+	if characterID == "" {
+		http.Redirect(w, r, characterURL(*r.URL, destinyUser.DestinyCharacters[0]), http.StatusFound)
+		return
+	}
+
+	manifest := h.Server.Manifests.Get(userLocale(h.Server, bungieUser, r))
+	kiosks := kiosk.FetchCharacterKioskStatus(bungieUser, destinyUser, characterID, h.Server.API, manifest)
+	saveSnapshots(h.Server, kiosks...)
+
+	data := overviewData{
+		User:             destinyUser.DisplayName,
+		Characters:       characters(*r.URL, destinyUser, characterID),
+		CurrentCharacter: string(characterID),
+		Total:            kiosk.TotalStats(kiosks),
+	}
+	for _, k := range kiosks {
+		data.Kiosks = append(data.Kiosks, overviewKiosk{
+			Summary: k.Summary(),
+			URL:     h.VendorPaths[k.VendorHash] + "?c=" + url.QueryEscape(string(characterID)),
+		})
+	}
+
+	if wantsJSON(r) {
+		writeJSON(w, data)
+		return
+	}
+	if err := overviewTemplate.Execute(w, data); err != nil {
+		panic(err)
+	}
+}
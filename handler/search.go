@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"html/template"
+	"net/http"
+	"strings"
+
+	"github.com/zhirsch/destinykioskstatus/db"
+	"github.com/zhirsch/destinykioskstatus/kiosk"
+	"github.com/zhirsch/destinykioskstatus/server"
+)
+
+// minSearchQueryLength avoids searches that would match nearly everything and
+// fetch every kiosk.
+const minSearchQueryLength = 2
+
+var searchTemplate = template.Must(template.New("search").Parse(`<!DOCTYPE html>
+<html>
+  <head>
+    <meta charset="UTF-8">
+    <title>Search</title>
+    <style>
+      @import url('https://fonts.googleapis.com/css?family=Nunito:300');
+      body {
+        font-family: "Nunito";
+      }
+      td > img {
+        width: 48px;
+        height: 48px;
+      }
+      .missing {
+        opacity: 0.3;
+      }
+    </style>
+  </head>
+  <body>
+    <form method="GET">
+      <input type="hidden" name="c" value="{{.CurrentCharacter}}" />
+      <input type="search" name="q" value="{{.Query}}" autofocus />
+      <input type="submit" value="Search" />
+    </form>
+    {{if .Query}}
+    {{if .Results}}
+    <table>
+      {{range .Results}}
+      <tr>
+        <td><img src="{{.Item.Icon}}" alt="" {{if .Item.Missing}}class="missing"{{end}} /></td>
+        <td>{{.Item.Name}}</td>
+        <td>{{.Vendor}}</td>
+        <td>{{.Category}}</td>
+        <td>{{if .Item.Missing}}Missing{{else}}Owned{{end}}</td>
+        <td>{{if .Item.ForSale}}For sale{{end}}</td>
+      </tr>
+      {{end}}
+    </table>
+    {{else}}
+    <p>No items match.</p>
+    {{end}}
+    {{end}}
+  </body>
+</html>
+`))
+
+type searchData struct {
+	Query            string
+	CurrentCharacter string
+	Results          []kiosk.SearchResult
+}
+
+// SearchHandler searches the names of the items sold by all the kiosks, and
+// shows whether the character is missing each match and whether it's for sale.
+type SearchHandler struct {
+	Server *server.Server
+}
+
+func (h SearchHandler) ServeHTTP(bungieUser *db.BungieUser, w http.ResponseWriter, r *http.Request) {
+	// TODO: Support multiple DestinyUsers on the same BungieUser.
+	destinyUser := bungieUser.DestinyUsers[0]
+
+	characterID := db.DestinyCharacterID(r.URL.Query().Get("c"))
+	if characterID == "" {
+		http.Redirect(w, r, characterURL(*r.URL, destinyUser.DestinyCharacters[0]), http.StatusFound)
+		return
+	}
+
+	data := searchData{
+		Query:            strings.TrimSpace(r.URL.Query().Get("q")),
+		CurrentCharacter: string(characterID),
+	}
+	if len(data.Query) >= minSearchQueryLength {
+		manifest := h.Server.Manifests.Get(userLocale(h.Server, bungieUser, r))
+		data.Results = kiosk.Search(bungieUser, destinyUser, characterID, data.Query, h.Server.API, manifest)
+	}
+
+	if wantsJSON(r) {
+		writeJSON(w, data)
+		return
+	}
+	if err := searchTemplate.Execute(w, data); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,188 @@
+package handler
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+	"net/mail"
+
+	"github.com/zhirsch/destinykioskstatus/db"
+	"github.com/zhirsch/destinykioskstatus/email"
+	"github.com/zhirsch/destinykioskstatus/server"
+)
+
+var settingsTemplate = template.Must(template.New("settings").Parse(`<!DOCTYPE html>
+<html>
+  <head>
+    <meta charset="UTF-8">
+    <title>Settings</title>
+  </head>
+  <body>
+    <div>{{.User}}</div>
+    {{if .Message}}<p>{{.Message}}</p>{{end}}
+    <form method="POST">
+      <input type="hidden" name="token" value="{{.FormToken}}" />
+      <input type="hidden" name="form" value="email" />
+      <label>
+        Email
+        <input type="email" name="email" value="{{if .Email}}{{.Email.Address}}{{end}}" />
+      </label>
+      {{if .Email}}{{if .Email.Verified}}(verified){{else}}(not verified){{end}}{{end}}
+      <input type="submit" value="Save" />
+    </form>
+    <form method="POST">
+      <input type="hidden" name="token" value="{{.FormToken}}" />
+      <input type="hidden" name="form" value="language" />
+      <label>
+        Language
+        <select name="language">
+          <option value="">Same as browser</option>
+          {{range .Locales}}
+          <option value="{{.}}" {{if eq . $.Preferences.Language}}selected="selected"{{end}}>{{.}}</option>
+          {{end}}
+        </select>
+      </label>
+      <input type="submit" value="Save" />
+    </form>
+  </body>
+</html>
+`))
+
+type settingsData struct {
+	User        string
+	Email       *db.Email
+	Preferences *db.Preferences
+	Locales     []string
+	Message     string
+	FormToken   string
+}
+
+// SettingsHandler lets the user set the address that notifications are sent
+// to and their preferred language.  Changing the address sends a verification
+// email to the new address; notifications aren't sent until the address is
+// verified.
+type SettingsHandler struct {
+	Server *server.Server
+}
+
+func (h SettingsHandler) ServeHTTP(bungieUser *db.BungieUser, w http.ResponseWriter, r *http.Request) {
+	var message string
+	if r.Method == "POST" {
+		if !checkFormToken(h.Server, bungieUser, w, r) {
+			return
+		}
+		switch r.FormValue("form") {
+		case "email":
+			current, err := h.Server.DB.SelectEmail(bungieUser.MembershipID)
+			if err != nil {
+				panic(err)
+			}
+			message = h.setEmail(bungieUser, current, r)
+		case "language":
+			message = h.setLanguage(bungieUser, r)
+		}
+	}
+
+	data := settingsData{
+		User:      bungieUser.DisplayName,
+		Locales:   h.Server.Manifests.Locales(),
+		Message:   message,
+		FormToken: formToken(h.Server, bungieUser),
+	}
+	var err error
+	if data.Email, err = h.Server.DB.SelectEmail(bungieUser.MembershipID); err != nil {
+		panic(err)
+	}
+	if data.Preferences, err = h.Server.DB.SelectPreferences(bungieUser.MembershipID); err != nil {
+		panic(err)
+	}
+
+	if err := settingsTemplate.Execute(w, data); err != nil {
+		panic(err)
+	}
+}
+
+// setLanguage updates the user's preferred language and returns a message to
+// show the user.
+func (h SettingsHandler) setLanguage(bungieUser *db.BungieUser, r *http.Request) string {
+	language := r.FormValue("language")
+	if language != "" && h.Server.Manifests.Match(language) != language {
+		return "That language isn't supported."
+	}
+	preferences, err := h.Server.DB.SelectPreferences(bungieUser.MembershipID)
+	if err != nil {
+		panic(err)
+	}
+	preferences.Language = language
+	if err := h.Server.DB.InsertPreferences(bungieUser.MembershipID, preferences); err != nil {
+		panic(err)
+	}
+	return "Your language has been saved."
+}
+
+// setEmail updates the user's address and returns a message to show the user.
+func (h SettingsHandler) setEmail(bungieUser *db.BungieUser, current *db.Email, r *http.Request) string {
+	addr, err := mail.ParseAddress(r.FormValue("email"))
+	if err != nil {
+		return "That doesn't look like an email address."
+	}
+	if current != nil && current.Verified && current.Address == addr.Address {
+		return "That address is already verified."
+	}
+
+	if err := h.Server.DB.InsertEmail(bungieUser.MembershipID, &db.Email{Address: addr.Address}); err != nil {
+		panic(err)
+	}
+
+	if h.Server.Sender == nil {
+		// Don't log the link: anyone who can read the logs could use it.
+		log.Printf("not sending verification email to %v", addr.Address)
+	} else {
+		links := email.Links{BaseURL: "https://" + r.Host, Signer: h.Server.Signer}
+		msg, err := email.NewVerifyMessage(addr.Address, links.Verify(bungieUser.MembershipID, addr.Address))
+		if err != nil {
+			panic(err)
+		}
+		if err := h.Server.Sender.Send(msg); err != nil {
+			log.Printf("unable to send verification email to %v: %v", bungieUser.MembershipID, err)
+			return "Unable to send the verification email.  Try again later."
+		}
+	}
+	return "Check your email for a verification link."
+}
+
+// VerifyEmailHandler handles the verification links sent by SettingsHandler.
+// It doesn't require authentication because the link's token is signed.
+type VerifyEmailHandler struct {
+	Server *server.Server
+}
+
+func (h VerifyEmailHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	membershipID, address, err := email.ParseVerifyToken(h.Server.Signer, r.FormValue("t"))
+	if err != nil {
+		log.Printf("bad verify token: %v", err)
+		http.Error(w, "invalid or expired verification link", http.StatusBadRequest)
+		return
+	}
+
+	// The user may have changed their address since the link was sent.
+	current, err := h.Server.DB.SelectEmail(membershipID)
+	if err != nil {
+		panic(err)
+	}
+	if current == nil || current.Address != address {
+		http.Error(w, "this verification link is for an old address", http.StatusBadRequest)
+		return
+	}
+
+	if !current.Verified {
+		current.Verified = true
+		if err := h.Server.DB.InsertEmail(membershipID, current); err != nil {
+			panic(err)
+		}
+		log.Printf("%v verified their email", membershipID)
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte("Your email has been verified.\n"))
+}
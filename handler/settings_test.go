@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/zhirsch/destinykioskstatus/db"
+	"github.com/zhirsch/destinykioskstatus/server"
+	"github.com/zhirsch/destinykioskstatus/sign"
+)
+
+func TestSettingsRejectsForgedForm(t *testing.T) {
+	signer, err := sign.NewSigner([]byte("0123456789abcdef0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The server has no database, so the handler fails if it gets past the
+	// token check.
+	h := SettingsHandler{&server.Server{Signer: signer}}
+	user := &db.BungieUser{MembershipID: "1"}
+
+	for _, form := range []url.Values{
+		{"form": {"email"}, "email": {"attacker@example.com"}},
+		{"form": {"language"}, "language": {"en"}},
+	} {
+		r := httptest.NewRequest("POST", "/settings", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(user, w, r)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("%v form: got status %v, want %v", form.Get("form"), w.Code, http.StatusForbidden)
+		}
+	}
+}
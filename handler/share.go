@@ -0,0 +1,310 @@
+package handler
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/zhirsch/destinykioskstatus/db"
+	"github.com/zhirsch/destinykioskstatus/kiosk"
+	"github.com/zhirsch/destinykioskstatus/server"
+)
+
+// sharePurpose signs the IDs of db.Shares.  The signature stops IDs from being
+// guessed, and deleting the Share revokes the token.
+const sharePurpose = "share"
+
+// lookupShare returns the share that the token in the request is for, or nil
+// if the token is bad or the share was revoked.
+func lookupShare(s *server.Server, r *http.Request) *db.Share {
+	id, err := s.Signer.Verify(sharePurpose, r.URL.Query().Get("t"), time.Now())
+	if err != nil {
+		return nil
+	}
+	share, err := s.DB.SelectShare(id)
+	if err != nil {
+		panic(err)
+	}
+	return share
+}
+
+// shareLinks are the links to a share.
+type shareLinks struct {
+	*db.Share
+	Vendor string
+
+	// PublicURL shows the kiosk to anyone, and FriendURL compares it with
+	// the viewer's own.
+	PublicURL string
+	FriendURL string
+}
+
+func newShareLinks(s *server.Server, r *http.Request, share *db.Share, manifest string) shareLinks {
+	q := url.Values{"t": {s.Signer.Sign(sharePurpose, share.ID, time.Time{})}}.Encode()
+	return shareLinks{
+		Share:     share,
+		Vendor:    kiosk.VendorName(share.VendorHash, s.Manifests.Get(manifest)),
+		PublicURL: "https://" + r.Host + "/shared?" + q,
+		FriendURL: "https://" + r.Host + "/friend?" + q,
+	}
+}
+
+var shareTemplate = template.Must(template.New("share").Parse(`<!DOCTYPE html>
+<html>
+  <head>
+    <meta charset="UTF-8">
+    <title>Share</title>
+    <style>
+      @import url('https://fonts.googleapis.com/css?family=Nunito:300');
+      body {
+        font-family: "Nunito";
+      }
+      td, th {
+        padding: 0 8px;
+        text-align: left;
+      }
+      input[type=text] {
+        width: 30em;
+      }
+    </style>
+  </head>
+  <body>
+    <div>{{.User}}</div>
+    {{if .Message}}<p>{{.Message}}</p>{{end}}
+    <p>
+      Anyone with a link can see your collection as of the last time that you
+      looked at it here.  The compare link lets a friend who is logged in see
+      it next to their own.  Revoke a link to stop it from working.
+    </p>
+    {{if .Shares}}
+    <table>
+      <tr>
+        <th>Kiosk</th>
+        <th>Character</th>
+        <th>Link</th>
+        <th>Compare link</th>
+        <th></th>
+      </tr>
+      {{range .Shares}}
+      <tr>
+        <td>{{.Vendor}}</td>
+        <td>{{.ClassName}}</td>
+        <td><input type="text" value="{{.PublicURL}}" readonly onfocus="this.select()" /></td>
+        <td><input type="text" value="{{.FriendURL}}" readonly onfocus="this.select()" /></td>
+        <td>
+          <form method="POST">
+            <input type="hidden" name="token" value="{{$.FormToken}}" />
+            <input type="hidden" name="form" value="revoke" />
+            <input type="hidden" name="id" value="{{.ID}}" />
+            <input type="submit" value="Revoke" />
+          </form>
+        </td>
+      </tr>
+      {{end}}
+    </table>
+    {{else}}
+    <p>You haven't shared anything.  Use the Share button on a kiosk page.</p>
+    {{end}}
+  </body>
+</html>
+`))
+
+type shareData struct {
+	User      string
+	Message   string
+	Shares    []shareLinks
+	FormToken string
+}
+
+// ShareHandler creates links that let other people see one of the user's
+// kiosks, lists them, and revokes them.
+type ShareHandler struct {
+	Server *server.Server
+}
+
+func (h ShareHandler) ServeHTTP(bungieUser *db.BungieUser, w http.ResponseWriter, r *http.Request) {
+	// TODO: Support multiple DestinyUsers on the same BungieUser.
+	destinyUser := bungieUser.DestinyUsers[0]
+
+	var message string
+	if r.Method == "POST" {
+		if !checkFormToken(h.Server, bungieUser, w, r) {
+			return
+		}
+		switch r.FormValue("form") {
+		case "create":
+			message = h.create(bungieUser, destinyUser, r)
+		case "revoke":
+			if err := h.Server.DB.DeleteShare(bungieUser.MembershipID, r.FormValue("id")); err != nil {
+				panic(err)
+			}
+			message = "The link was revoked."
+		}
+	}
+
+	shares, err := h.Server.DB.SelectShares(bungieUser.MembershipID)
+	if err != nil {
+		panic(err)
+	}
+	data := shareData{
+		User:      destinyUser.DisplayName,
+		Message:   message,
+		FormToken: formToken(h.Server, bungieUser),
+	}
+	locale := userLocale(h.Server, bungieUser, r)
+	for _, share := range shares {
+		data.Shares = append(data.Shares, newShareLinks(h.Server, r, share, locale))
+	}
+	if err := shareTemplate.Execute(w, data); err != nil {
+		panic(err)
+	}
+}
+
+func (h ShareHandler) create(bungieUser *db.BungieUser, destinyUser *db.DestinyUser, r *http.Request) string {
+	// Only the user's own characters can be shared.
+	var character *db.DestinyCharacter
+	for _, c := range destinyUser.DestinyCharacters {
+		if c.CharacterID == db.DestinyCharacterID(r.FormValue("c")) {
+			character = c
+		}
+	}
+	if character == nil {
+		return "That isn't one of your characters."
+	}
+	vendorHash, err := strconv.ParseUint(r.FormValue("v"), 10, 32)
+	if err != nil || !isKiosk(uint32(vendorHash)) {
+		return "That isn't a kiosk."
+	}
+
+	share := &db.Share{
+		MembershipID: bungieUser.MembershipID,
+		Owner:        destinyUser.DisplayName,
+		CharacterID:  character.CharacterID,
+		ClassName:    character.ClassName,
+		VendorHash:   uint32(vendorHash),
+		CreatedAt:    time.Now(),
+	}
+	if err := h.Server.DB.InsertShare(share); err != nil {
+		panic(err)
+	}
+	return "Created a link."
+}
+
+func isKiosk(vendorHash uint32) bool {
+	for _, h := range kiosk.VendorHashes {
+		if h == vendorHash {
+			return true
+		}
+	}
+	return false
+}
+
+// SharedHandler shows a shared kiosk to anyone with the link, from the latest
+// snapshot of the owner's collection.  It doesn't need the viewer to log in,
+// and doesn't use the owner's token.
+type SharedHandler struct {
+	Server *server.Server
+}
+
+func (h SharedHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	share := lookupShare(h.Server, r)
+	if share == nil {
+		http.Error(w, "This link doesn't work anymore.", http.StatusNotFound)
+		return
+	}
+	snapshot, err := h.Server.DB.SelectLatestSnapshot(share.CharacterID, share.VendorHash)
+	if err != nil {
+		panic(err)
+	}
+	if snapshot == nil {
+		http.Error(w, "Nothing has been shared yet.", http.StatusNotFound)
+		return
+	}
+
+	manifest := h.Server.Manifests.Get(h.Server.Manifests.Match(parseAcceptLanguage(r.Header.Get("Accept-Language"))...))
+	data := Data{
+		Data:         kiosk.FromSnapshot(snapshot, manifest),
+		Shared:       share,
+		SnapshotTime: snapshot.TakenAt,
+	}
+	data.Summary = data.Data.Summary()
+	data.User = share.Owner
+
+	if wantsJSON(r) {
+		writeJSON(w, data.Data)
+		return
+	}
+	if err := h.Server.Template.Execute(w, data); err != nil {
+		panic(err)
+	}
+}
+
+// FriendHandler shows a kiosk that another user shared side by side with the
+// user's own, highlighting the items that only one of them has.
+type FriendHandler struct {
+	Server *server.Server
+
+	// VendorPaths are the paths of the kiosk pages, by vendor hash.
+	VendorPaths map[uint32]string
+}
+
+func (h FriendHandler) ServeHTTP(bungieUser *db.BungieUser, w http.ResponseWriter, r *http.Request) {
+	share := lookupShare(h.Server, r)
+	if share == nil {
+		http.Error(w, "This link doesn't work anymore.", http.StatusNotFound)
+		return
+	}
+
+	// TODO: Support multiple DestinyUsers on the same BungieUser.
+	destinyUser := bungieUser.DestinyUsers[0]
+
+	characterID := db.DestinyCharacterID(r.URL.Query().Get("c"))
+	if characterID == "" {
+		http.Redirect(w, r, characterURL(*r.URL, destinyUser.DestinyCharacters[0]), http.StatusFound)
+		return
+	}
+	var className string
+	for _, c := range destinyUser.DestinyCharacters {
+		if c.CharacterID == characterID {
+			className = c.ClassName
+		}
+	}
+	if className == "" {
+		http.Error(w, "bad character", http.StatusBadRequest)
+		return
+	}
+
+	snapshot, err := h.Server.DB.SelectLatestSnapshot(share.CharacterID, share.VendorHash)
+	if err != nil {
+		panic(err)
+	}
+	if snapshot == nil {
+		http.Error(w, "Nothing has been shared yet.", http.StatusNotFound)
+		return
+	}
+
+	manifest := h.Server.Manifests.Get(userLocale(h.Server, bungieUser, r))
+	mine := kiosk.FetchKioskStatus(bungieUser, destinyUser, characterID, share.VendorHash, h.Server.API, manifest)
+	saveSnapshots(h.Server, mine)
+	theirs := kiosk.FromSnapshot(snapshot, manifest)
+
+	columns := []string{
+		fmt.Sprintf("You (%v)", className),
+		fmt.Sprintf("%v (%v)", share.Owner, share.ClassName),
+	}
+	data := compareData{
+		Comparison: kiosk.Compare(destinyUser.DisplayName, columns, []kiosk.Data{mine, theirs}),
+		BackURL:    h.VendorPaths[share.VendorHash] + "?c=" + url.QueryEscape(string(characterID)),
+		Note:       fmt.Sprintf("%v's collection as of %v.", share.Owner, snapshot.TakenAt.Format("January 2, 2006")),
+	}
+	if wantsJSON(r) {
+		writeJSON(w, data.Comparison)
+		return
+	}
+	if err := compareTemplate.Execute(w, data); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+
+	"github.com/zhirsch/destinykioskstatus/db"
+	"github.com/zhirsch/destinykioskstatus/email"
+	"github.com/zhirsch/destinykioskstatus/server"
+)
+
+var unsubscribeTemplate = template.Must(template.New("unsubscribe").Parse(`<!DOCTYPE html>
+<html>
+  <head>
+    <meta charset="UTF-8">
+    <title>Unsubscribe</title>
+  </head>
+  <body>
+    {{if .Done}}
+    <p>You have been unsubscribed from {{.What}}.</p>
+    {{else}}
+    <form method="POST">
+      <input type="hidden" name="t" value="{{.Token}}" />
+      <input type="submit" value="Unsubscribe from {{.What}}" />
+    </form>
+    {{end}}
+  </body>
+</html>
+`))
+
+// UnsubscribeHandler handles the unsubscribe links in the notification emails.
+// It doesn't require authentication because the link's token is signed.  A GET
+// shows a confirmation form, so that link prefetchers don't unsubscribe the
+// user, and a POST (including RFC 8058 one-click unsubscribes) unsubscribes.
+type UnsubscribeHandler struct {
+	Server *server.Server
+}
+
+func (h UnsubscribeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "POST" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// The token is in the query for both the GET and the one-click POST, and
+	// in the form for the confirmation POST.
+	token := r.FormValue("t")
+	membershipID, vendorHash, err := email.ParseUnsubscribeToken(h.Server.Signer, token)
+	if err != nil {
+		log.Printf("bad unsubscribe token: %v", err)
+		http.Error(w, "invalid unsubscribe link", http.StatusBadRequest)
+		return
+	}
+
+	data := struct {
+		Token string
+		What  string
+		Done  bool
+	}{
+		Token: token,
+		What:  "emails about this kiosk",
+	}
+	switch vendorHash {
+	case db.AllVendors:
+		data.What = "all emails"
+	case db.WishlistAlerts:
+		data.What = "wishlist alerts"
+	}
+	if r.Method == "POST" {
+		if err := h.Server.DB.InsertUnsubscription(membershipID, vendorHash); err != nil {
+			panic(err)
+		}
+		log.Printf("%v unsubscribed from %v", membershipID, vendorHash)
+		data.Done = true
+	}
+
+	if err := unsubscribeTemplate.Execute(w, data); err != nil {
+		panic(err)
+	}
+}
@@ -1,8 +1,11 @@
 package handler
 
 import (
+	"log"
 	"net/http"
 	"net/url"
+	"strconv"
+	"time"
 
 	"github.com/zhirsch/destinykioskstatus/db"
 	"github.com/zhirsch/destinykioskstatus/kiosk"
@@ -18,6 +21,43 @@ type Data struct {
 	kiosk.Data
 	Characters       []Character
 	CurrentCharacter string
+	SourceOptions    []SourceOption
+	CompareURL       string
+
+	// Filter is the filter that was applied to the items, and
+	// CategoryTitles are the titles of all the categories to filter by.
+	Filter         kiosk.Filter
+	CategoryTitles []string
+
+	// Summary is the completion statistics of the kiosk from before Filter
+	// was applied, so that hiding items doesn't change them.
+	Summary kiosk.Summary
+
+	// AcquiredAt is when each item was first seen unlocked, from the
+	// snapshots of the kiosk.
+	AcquiredAt map[uint32]time.Time
+
+	// FormToken goes in the forms that change the user's data.
+	FormToken string
+
+	// Wishlist is the user's wishlist, or nil if the page can't change it.
+	Wishlist *db.Wishlist
+
+	// Shared is set when the page is being shown through a share link, from
+	// the snapshot taken at SnapshotTime.
+	Shared       *db.Share
+	SnapshotTime time.Time
+}
+
+// CategoryStats returns the unfiltered statistics of the category with the
+// title, or nil if there isn't one.
+func (d Data) CategoryStats(title string) *kiosk.Stats {
+	for _, category := range d.Summary.Categories {
+		if category.Title == title {
+			return &category.Stats
+		}
+	}
+	return nil
 }
 
 type Character struct {
@@ -27,10 +67,24 @@ type Character struct {
 	URL     string
 }
 
+// A SourceOption is an option in the menu that filters items by source.
+type SourceOption struct {
+	Hash    uint32
+	Name    string
+	Current bool
+	URL     string
+}
+
 func (h VendorHandler) ServeHTTP(bungieUser *db.BungieUser, w http.ResponseWriter, r *http.Request) {
 	// TODO: Support multiple DestinyUsers on the same BungieUser.
 	destinyUser := bungieUser.DestinyUsers[0]
 
+	// Compare the kiosk across all the characters instead.
+	if r.URL.Query().Get("compare") != "" {
+		h.serveComparison(bungieUser, destinyUser, w, r)
+		return
+	}
+
 	// Get the character to display info for.  If there isn't a character,
 	// redirect to the first character.
 	characterID := db.DestinyCharacterID(r.URL.Query().Get("c"))
@@ -38,23 +92,138 @@ func (h VendorHandler) ServeHTTP(bungieUser *db.BungieUser, w http.ResponseWrite
 		http.Redirect(w, r, characterURL(*r.URL, destinyUser.DestinyCharacters[0]), http.StatusFound)
 		return
 	}
+	if !ownsCharacter(destinyUser, characterID) {
+		http.Error(w, "bad character", http.StatusBadRequest)
+		return
+	}
+
+	// Get the source to filter items by, if any.
+	var sourceHash uint32
+	if s := r.URL.Query().Get("source"); s != "" {
+		v, err := strconv.ParseUint(s, 10, 32)
+		if err != nil {
+			http.Error(w, "bad source", http.StatusBadRequest)
+			return
+		}
+		sourceHash = uint32(v)
+	}
+
+	// Get the filter and order of the items.
+	filter, err := parseFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
+	kioskData := kiosk.FetchKioskStatus(bungieUser, destinyUser, characterID, h.VendorHash, h.Server.API, h.Server.Manifests.Get(userLocale(h.Server, bungieUser, r)))
+	saveSnapshots(h.Server, kioskData)
+	snapshots, err := h.Server.DB.SelectSnapshots(characterID, h.VendorHash)
+	if err != nil {
+		panic(err)
+	}
 	data := Data{
-		Data:             kiosk.FetchKioskStatus(bungieUser, destinyUser, characterID, h.VendorHash, h.Server.API, h.Server.Manifest),
+		Data:             kioskData,
 		CurrentCharacter: string(characterID),
+		CompareURL:       compareURL(*r.URL, true),
+		AcquiredAt:       db.AcquiredAt(snapshots),
+		FormToken:        formToken(h.Server, bungieUser),
+		SourceOptions: []SourceOption{{
+			Name:    "All sources",
+			Current: sourceHash == 0,
+			URL:     sourceURL(*r.URL, 0),
+		}},
+	}
+	for _, source := range kioskData.Sources() {
+		data.SourceOptions = append(data.SourceOptions, SourceOption{
+			Hash:    source.Hash,
+			Name:    source.Name,
+			Current: source.Hash == sourceHash,
+			URL:     sourceURL(*r.URL, source.Hash),
+		})
+	}
+	for _, category := range kioskData.Categories {
+		data.CategoryTitles = append(data.CategoryTitles, category.Title)
+	}
+	if sourceHash != 0 {
+		data.Data = kioskData.FilterBySource(sourceHash)
 	}
+	data.Summary = data.Data.Summary()
+	data.Filter = filter
+	data.Data = filter.Apply(data.Data)
+	data.Characters = characters(*r.URL, destinyUser, characterID)
+	wishlist, err := h.Server.DB.SelectWishlist(bungieUser.MembershipID)
+	if err != nil {
+		panic(err)
+	}
+	data.Wishlist = wishlist
+
+	if wantsJSON(r) {
+		writeJSON(w, vendorJSON{data.Data, data.Filter, data.AcquiredAt, data.Summary.Stats, data.Summary.Categories})
+		return
+	}
+	if err := h.Server.Template.Execute(w, data); err != nil {
+		panic(err)
+	}
+}
+
+// vendorJSON is the JSON form of a kiosk page, with its statistics.
+type vendorJSON struct {
+	kiosk.Data
+	Filter        kiosk.Filter
+	AcquiredAt    map[uint32]time.Time
+	Stats         kiosk.Stats
+	CategoryStats []kiosk.CategorySummary
+}
+
+// parseFilter returns the filter in the query parameters of a kiosk page.
+func parseFilter(q url.Values) (kiosk.Filter, error) {
+	sort, err := kiosk.ParseSortOrder(q.Get("sort"))
+	if err != nil {
+		return kiosk.Filter{}, err
+	}
+	return kiosk.Filter{
+		Missing:   q.Get("missing") != "",
+		ForSale:   q.Get("forsale") != "",
+		Available: q.Get("available") != "",
+		Locked:    q.Get("locked") != "",
+		Category:  q.Get("category"),
+		Sort:      sort,
+	}, nil
+}
+
+// characters returns the menu of the user's characters.
+func characters(u url.URL, destinyUser *db.DestinyUser, characterID db.DestinyCharacterID) []Character {
+	var characters []Character
 	for _, character := range destinyUser.DestinyCharacters {
-		data.Characters = append(data.Characters, Character{
+		characters = append(characters, Character{
 			ID:      string(character.CharacterID),
 			Class:   character.ClassName,
 			Current: character.CharacterID == characterID,
-			URL:     characterURL(*r.URL, character),
+			URL:     characterURL(u, character),
 		})
 	}
+	return characters
+}
 
-	if err := h.Server.Template.Execute(w, data); err != nil {
-		panic(err)
+// saveSnapshots records the collection history of the kiosks.  Failing to
+// record it doesn't stop the page from being shown.
+func saveSnapshots(s *server.Server, kioskData ...kiosk.Data) {
+	now := time.Now()
+	for _, data := range kioskData {
+		if _, err := s.DB.InsertSnapshot(data.Snapshot(now)); err != nil {
+			log.Printf("failed to save snapshot of %v for %v: %v", data.VendorHash, data.CharacterID, err)
+		}
+	}
+}
+
+// ownsCharacter returns whether the character is one of the user's.
+func ownsCharacter(destinyUser *db.DestinyUser, characterID db.DestinyCharacterID) bool {
+	for _, character := range destinyUser.DestinyCharacters {
+		if character.CharacterID == characterID {
+			return true
+		}
 	}
+	return false
 }
 
 func characterURL(u url.URL, destinyCharacter *db.DestinyCharacter) string {
@@ -63,3 +232,14 @@ func characterURL(u url.URL, destinyCharacter *db.DestinyCharacter) string {
 	u.RawQuery = q.Encode()
 	return u.String()
 }
+
+func sourceURL(u url.URL, sourceHash uint32) string {
+	q := u.Query()
+	if sourceHash == 0 {
+		q.Del("source")
+	} else {
+		q.Set("source", strconv.FormatUint(uint64(sourceHash), 10))
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/zhirsch/destinykioskstatus/db"
+	"github.com/zhirsch/destinykioskstatus/kiosk"
+	"github.com/zhirsch/destinykioskstatus/server"
+)
+
+var wishlistTemplate = template.Must(template.New("wishlist").Parse(`<!DOCTYPE html>
+<html>
+  <head>
+    <meta charset="UTF-8">
+    <title>Wishlist</title>
+    <style>
+      @import url('https://fonts.googleapis.com/css?family=Nunito:300');
+      body {
+        font-family: "Nunito";
+      }
+      td > img {
+        width: 48px;
+        height: 48px;
+      }
+      .missing {
+        opacity: 0.3;
+      }
+      .forsale {
+        font-weight: bold;
+      }
+    </style>
+    <script>
+      function switchURL(url) {
+        document.location = url;
+      }
+    </script>
+  </head>
+  <body>
+    <div>
+      {{.User}}
+      &mdash;
+      <a href="/overview?c={{.CurrentCharacter}}">Overview</a>
+      &mdash;
+      <select onchange="switchURL(this.value)">
+        {{range .Characters}}
+        <option value="{{.URL}}" {{if .Current}}selected="selected"{{end}}>{{.Class}}</option>
+        {{end}}
+      </select>
+    </div>
+    <p>
+      You'll get an email as soon as something on your wishlist that you're
+      missing is for sale to any of your characters.  This shows what's sold
+      to this character.
+    </p>
+    {{if .Items}}
+    <table>
+      {{range .Items}}
+      <tr>
+        <td><img src="{{.Item.Icon}}" alt="" {{if .Item.Missing}}class="missing"{{end}} /></td>
+        <td>{{.Item.Name}}</td>
+        <td>{{.Vendor}}</td>
+        <td>{{.Category}}</td>
+        <td>{{if .Item.Missing}}Missing{{else}}Owned{{end}}</td>
+        <td>{{if .Item.ForSale}}<span class="forsale">For sale</span>{{end}}</td>
+        <td>
+          <form method="POST">
+            <input type="hidden" name="token" value="{{$.FormToken}}" />
+            <input type="hidden" name="form" value="remove" />
+            <input type="hidden" name="i" value="{{.Item.Hash}}" />
+            <input type="submit" value="Remove" />
+          </form>
+        </td>
+      </tr>
+      {{end}}
+    </table>
+    {{else}}
+    <p>Your wishlist is empty.  Add items to it from the kiosk pages.</p>
+    {{end}}
+  </body>
+</html>
+`))
+
+type wishlistData struct {
+	User             string
+	Characters       []Character
+	CurrentCharacter string
+	Items            []kiosk.SearchResult
+	FormToken        string
+}
+
+// WishlistHandler shows the status of the items on the user's wishlist, and
+// adds and removes items.
+type WishlistHandler struct {
+	Server *server.Server
+}
+
+func (h WishlistHandler) ServeHTTP(bungieUser *db.BungieUser, w http.ResponseWriter, r *http.Request) {
+	if r.Method == "POST" {
+		h.update(bungieUser, w, r)
+		return
+	}
+
+	// TODO: Support multiple DestinyUsers on the same BungieUser.
+	destinyUser := bungieUser.DestinyUsers[0]
+
+	characterID := db.DestinyCharacterID(r.URL.Query().Get("c"))
+	if characterID == "" {
+		http.Redirect(w, r, characterURL(*r.URL, destinyUser.DestinyCharacters[0]), http.StatusFound)
+		return
+	}
+
+	wishlist, err := h.Server.DB.SelectWishlist(bungieUser.MembershipID)
+	if err != nil {
+		panic(err)
+	}
+	data := wishlistData{
+		User:             destinyUser.DisplayName,
+		Characters:       characters(*r.URL, destinyUser, characterID),
+		CurrentCharacter: string(characterID),
+		FormToken:        formToken(h.Server, bungieUser),
+	}
+	if len(wishlist.Alerted) != 0 {
+		manifest := h.Server.Manifests.Get(userLocale(h.Server, bungieUser, r))
+		kiosks := kiosk.FetchCharacterKioskStatus(bungieUser, destinyUser, characterID, h.Server.API, manifest)
+		saveSnapshots(h.Server, kiosks...)
+		data.Items = kiosk.Wishlisted(kiosks, wishlist)
+	}
+
+	if wantsJSON(r) {
+		writeJSON(w, data)
+		return
+	}
+	if err := wishlistTemplate.Execute(w, data); err != nil {
+		panic(err)
+	}
+}
+
+// update adds an item to the wishlist or removes one, and then goes back to
+// the page that the form was on.
+func (h WishlistHandler) update(bungieUser *db.BungieUser, w http.ResponseWriter, r *http.Request) {
+	if !checkFormToken(h.Server, bungieUser, w, r) {
+		return
+	}
+	itemHash, err := strconv.ParseUint(r.FormValue("i"), 10, 32)
+	if err != nil {
+		http.Error(w, "bad item", http.StatusBadRequest)
+		return
+	}
+	switch r.FormValue("form") {
+	case "add":
+		err = h.Server.DB.InsertWishlistItem(bungieUser.MembershipID, uint32(itemHash))
+	case "remove":
+		err = h.Server.DB.DeleteWishlistItem(bungieUser.MembershipID, uint32(itemHash))
+	default:
+		http.Error(w, "bad form", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		panic(err)
+	}
+
+	// Only go back to a page on this site.
+	next := "/wishlist"
+	if referer, err := url.Parse(r.Referer()); err == nil && referer.Host == r.Host && referer.Path != "" {
+		next = referer.RequestURI()
+	}
+	http.Redirect(w, r, next, http.StatusSeeOther)
+}
@@ -0,0 +1,144 @@
+package kiosk
+
+import (
+	"sync"
+
+	"github.com/zhirsch/destinykioskstatus/api"
+	"github.com/zhirsch/destinykioskstatus/db"
+)
+
+// A Comparison is the items of a kiosk with their status for several
+// characters, which may belong to different users.  Some items are only sold
+// to some characters, like class armor.
+type Comparison struct {
+	VendorHash uint32
+	Title      string
+	User       string
+
+	// Columns name the characters.
+	Columns       []string
+	Categories    []ComparisonCategory
+	StaleManifest bool
+}
+
+type ComparisonCategory struct {
+	Title string
+	Rows  []ComparisonRow
+}
+
+// A ComparisonRow is an item, and its status for each character in the same
+// order as Comparison.Columns.  The Missing and ForSale fields of Item
+// aren't meaningful; use Statuses instead.
+type ComparisonRow struct {
+	Item     Item
+	Statuses []ItemStatus
+}
+
+type ItemStatus struct {
+	// Sold is whether the kiosk sells the item to the character.  If not,
+	// the other fields are false.
+	Sold    bool
+	Missing bool
+	ForSale bool
+}
+
+// MissingAny returns whether any character is missing the item.
+func (r ComparisonRow) MissingAny() bool {
+	for _, status := range r.Statuses {
+		if status.Missing {
+			return true
+		}
+	}
+	return false
+}
+
+// Differs returns whether some of the characters that the item is sold to
+// have it and others are missing it.
+func (r ComparisonRow) Differs() bool {
+	owned, missing := false, false
+	for _, status := range r.Statuses {
+		owned = owned || (status.Sold && !status.Missing)
+		missing = missing || status.Missing
+	}
+	return owned && missing
+}
+
+// FetchKioskStatusForCharacters fetches the status of the kiosk for every
+// character of the user at the same time.  The data is in the same order as
+// destinyUser.DestinyCharacters.
+func FetchKioskStatusForCharacters(bungieUser *db.BungieUser, destinyUser *db.DestinyUser, vendorHash uint32, client *api.Client, manifest *api.Manifest) []Data {
+	data := make([]Data, len(destinyUser.DestinyCharacters))
+	panics := make([]interface{}, len(destinyUser.DestinyCharacters))
+	var wg sync.WaitGroup
+	for i, character := range destinyUser.DestinyCharacters {
+		wg.Add(1)
+		go func(i int, characterID db.DestinyCharacterID) {
+			defer wg.Done()
+			// FetchKioskStatus panics on errors, which would crash the
+			// program if it happened on this goroutine, so pass it on to
+			// the caller.
+			defer func() { panics[i] = recover() }()
+			data[i] = FetchKioskStatus(bungieUser, destinyUser, characterID, vendorHash, client, manifest)
+		}(i, character.CharacterID)
+	}
+	wg.Wait()
+	for _, p := range panics {
+		if p != nil {
+			panic(p)
+		}
+	}
+	return data
+}
+
+// CompareCharacters compares the status of a kiosk for each of the user's
+// characters, as returned by FetchKioskStatusForCharacters.
+func CompareCharacters(destinyUser *db.DestinyUser, data []Data) Comparison {
+	var columns []string
+	for _, character := range destinyUser.DestinyCharacters {
+		columns = append(columns, character.ClassName)
+	}
+	return Compare(destinyUser.DisplayName, columns, data)
+}
+
+// Compare combines the status of a kiosk for several characters, with
+// columns naming the characters.  The categories and items are in the order
+// that they're first seen.
+func Compare(user string, columns []string, data []Data) Comparison {
+	comparison := Comparison{
+		User:    user,
+		Columns: columns,
+	}
+	type position struct{ category, row int }
+	categories := make(map[string]int)
+	rows := make(map[uint32]position)
+	for i, d := range data {
+		comparison.VendorHash = d.VendorHash
+		comparison.Title = d.Title
+		comparison.StaleManifest = comparison.StaleManifest || d.StaleManifest
+		for _, category := range d.Categories {
+			c, ok := categories[category.Title]
+			if !ok {
+				c = len(comparison.Categories)
+				categories[category.Title] = c
+				comparison.Categories = append(comparison.Categories, ComparisonCategory{Title: category.Title})
+			}
+			for _, item := range category.Items {
+				p, ok := rows[item.Hash]
+				if !ok {
+					p = position{c, len(comparison.Categories[c].Rows)}
+					rows[item.Hash] = p
+					comparison.Categories[c].Rows = append(comparison.Categories[c].Rows, ComparisonRow{
+						Item:     item,
+						Statuses: make([]ItemStatus, len(data)),
+					})
+				}
+				comparison.Categories[p.category].Rows[p.row].Statuses[i] = ItemStatus{
+					Sold:    true,
+					Missing: item.Missing,
+					ForSale: item.ForSale,
+				}
+			}
+		}
+	}
+	return comparison
+}
@@ -0,0 +1,107 @@
+package kiosk
+
+import (
+	"fmt"
+	"sort"
+)
+
+// A SortOrder is the order of the items in each category.
+type SortOrder string
+
+const (
+	// SortDefault keeps the items in the vendor's order.
+	SortDefault SortOrder = ""
+	SortName    SortOrder = "name"
+	// SortRarity puts the rarest items first, and sorts items with the same
+	// rarity by name.
+	SortRarity SortOrder = "rarity"
+)
+
+// ParseSortOrder returns the sort order named s.
+func ParseSortOrder(s string) (SortOrder, error) {
+	switch order := SortOrder(s); order {
+	case SortDefault, SortName, SortRarity:
+		return order, nil
+	}
+	return SortDefault, fmt.Errorf("unknown sort order %q", s)
+}
+
+// A Filter chooses which of a kiosk's items to show, and in what order.  The
+// zero Filter shows every item in the vendor's order.
+type Filter struct {
+	// Missing and ForSale keep only the items that the character is missing
+	// or that are for sale.
+	Missing bool
+	ForSale bool
+
+	// Available hides the items that the vendor gave failure reasons for,
+	// like items that need a higher rank.
+	Available bool
+
+	// Locked keeps only the items that the vendor gave failure reasons
+	// for.  With ForSale, it shows the items that are for sale but that the
+	// character can't buy yet.
+	Locked bool
+
+	// Category keeps only the category with this title, if it's set.
+	Category string
+
+	Sort SortOrder
+}
+
+// Keep returns whether the filter shows the item.
+func (f Filter) Keep(item Item) bool {
+	return (!f.Missing || item.Missing) &&
+		(!f.ForSale || item.ForSale) &&
+		(!f.Available || !item.Failed()) &&
+		(!f.Locked || item.Failed())
+}
+
+// GroupByTier returns whether the items should be shown grouped by rarity.
+// They aren't when sorted by name, so that the order can be seen.
+func (f Filter) GroupByTier() bool {
+	return f.Sort != SortName
+}
+
+// Apply returns a copy of the data with only the items that the filter keeps,
+// in the filter's order.  Categories without any such items are removed.
+func (f Filter) Apply(d Data) Data {
+	filtered := d
+	filtered.Categories = nil
+	for _, category := range d.Categories {
+		if f.Category != "" && category.Title != f.Category {
+			continue
+		}
+		c := Category{Title: category.Title}
+		for _, item := range category.Items {
+			if f.Keep(item) {
+				c.Items = append(c.Items, item)
+			}
+		}
+		if len(c.Items) == 0 {
+			continue
+		}
+		switch f.Sort {
+		case SortName:
+			sort.SliceStable(c.Items, func(i, j int) bool { return c.Items[i].Name < c.Items[j].Name })
+		case SortRarity:
+			sort.SliceStable(c.Items, func(i, j int) bool {
+				if c.Items[i].Tier != c.Items[j].Tier {
+					return c.Items[i].Tier > c.Items[j].Tier
+				}
+				return c.Items[i].Name < c.Items[j].Name
+			})
+		}
+		filtered.Categories = append(filtered.Categories, c)
+	}
+	return filtered
+}
+
+// Ungrouped returns the category's items as a single group, in the same order
+// as in the category.
+func (c Category) Ungrouped() []TierGroup {
+	if len(c.Items) == 0 {
+		return nil
+	}
+	return []TierGroup{{Items: c.Items}}
+}
@@ -0,0 +1,82 @@
+package kiosk
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/zhirsch/destinykioskstatus/api"
+)
+
+func TestFilterApply(t *testing.T) {
+	locked := []Failure{{Index: 0, Text: "Requires rank 3"}}
+	data := Data{
+		Title: "Ships",
+		Categories: []Category{
+			{Title: "Ships", Items: []Item{
+				{Hash: 1, Name: "Bravo", Tier: api.TierTypeRare},
+				{Hash: 2, Name: "Alpha", Tier: api.TierTypeExotic, Missing: true, ForSale: true},
+				{Hash: 3, Name: "Delta", Tier: api.TierTypeSuperior, Missing: true, ForSale: true, Failures: locked},
+				{Hash: 4, Name: "Charlie", Tier: api.TierTypeSuperior, Missing: true},
+			}},
+			{Title: "Sparrows", Items: []Item{
+				{Hash: 5, Name: "Echo", Tier: api.TierTypeCommon, Missing: true, ForSale: true},
+			}},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   map[string][]uint32
+	}{
+		{"zero", Filter{}, map[string][]uint32{"Ships": {1, 2, 3, 4}, "Sparrows": {5}}},
+		{"missing", Filter{Missing: true}, map[string][]uint32{"Ships": {2, 3, 4}, "Sparrows": {5}}},
+		{"for sale", Filter{ForSale: true}, map[string][]uint32{"Ships": {2, 3}, "Sparrows": {5}}},
+		{"missing and for sale", Filter{Missing: true, ForSale: true}, map[string][]uint32{"Ships": {2, 3}, "Sparrows": {5}}},
+		{"available", Filter{Available: true}, map[string][]uint32{"Ships": {1, 2, 4}, "Sparrows": {5}}},
+		{"locked", Filter{Locked: true}, map[string][]uint32{"Ships": {3}}},
+		{"locked and for sale", Filter{Locked: true, ForSale: true}, map[string][]uint32{"Ships": {3}}},
+		{"available and locked", Filter{Available: true, Locked: true}, map[string][]uint32{}},
+		{"category", Filter{Category: "Sparrows"}, map[string][]uint32{"Sparrows": {5}}},
+		{"category and missing", Filter{Category: "Ships", Missing: true, Available: true}, map[string][]uint32{"Ships": {2, 4}}},
+		{"unknown category", Filter{Category: "Emotes"}, map[string][]uint32{}},
+		{"name", Filter{Sort: SortName}, map[string][]uint32{"Ships": {2, 1, 4, 3}, "Sparrows": {5}}},
+		{"rarity", Filter{Sort: SortRarity}, map[string][]uint32{"Ships": {2, 4, 3, 1}, "Sparrows": {5}}},
+		{"missing by rarity", Filter{Missing: true, Sort: SortRarity}, map[string][]uint32{"Ships": {2, 4, 3}, "Sparrows": {5}}},
+	}
+	for _, tt := range tests {
+		filtered := tt.filter.Apply(data)
+		got := map[string][]uint32{}
+		for _, category := range filtered.Categories {
+			for _, item := range category.Items {
+				got[category.Title] = append(got[category.Title], item.Hash)
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%v: got %v, want %v", tt.name, got, tt.want)
+		}
+		if filtered.Title != data.Title {
+			t.Errorf("%v: got title %q, want %q", tt.name, filtered.Title, data.Title)
+		}
+	}
+
+	// Applying a filter mustn't reorder the original data.
+	if got := data.Categories[0].Items[0].Hash; got != 1 {
+		t.Errorf("Apply changed the original data: first item is %v", got)
+	}
+}
+
+func TestParseSortOrder(t *testing.T) {
+	for _, s := range []string{"", "name", "rarity"} {
+		order, err := ParseSortOrder(s)
+		if err != nil {
+			t.Errorf("ParseSortOrder(%q) failed: %v", s, err)
+		}
+		if string(order) != s {
+			t.Errorf("ParseSortOrder(%q) = %q", s, order)
+		}
+	}
+	if _, err := ParseSortOrder("price"); err == nil {
+		t.Error("ParseSortOrder(\"price\") didn't fail")
+	}
+}
@@ -0,0 +1,51 @@
+package kiosk
+
+import (
+	"time"
+
+	"github.com/zhirsch/destinykioskstatus/db"
+)
+
+// A History is how a character's collection of a kiosk's items grew, from the
+// snapshots of it.
+type History struct {
+	VendorHash uint32
+	Title      string
+
+	// AcquiredAt is when each item was first seen unlocked.  Items that
+	// were unlocked in the first snapshot were acquired at some time before
+	// it.
+	AcquiredAt map[uint32]time.Time
+
+	// Progress is the statistics at each snapshot, oldest first.
+	Progress []HistoryPoint
+}
+
+type HistoryPoint struct {
+	TakenAt time.Time
+	Owned   int
+	Total   int
+	Percent int
+}
+
+// NewHistory returns the history of the kiosk in snapshots, which must be
+// oldest first as returned by db.Storage.SelectSnapshots.
+func NewHistory(vendorHash uint32, title string, snapshots []*db.Snapshot) History {
+	history := History{
+		VendorHash: vendorHash,
+		Title:      title,
+		AcquiredAt: db.AcquiredAt(snapshots),
+	}
+	for _, snapshot := range snapshots {
+		point := HistoryPoint{
+			TakenAt: snapshot.TakenAt,
+			Owned:   snapshot.Unlocked(),
+			Total:   len(snapshot.Items),
+		}
+		if point.Total > 0 {
+			point.Percent = point.Owned * 100 / point.Total
+		}
+		history.Progress = append(history.Progress, point)
+	}
+	return history
+}
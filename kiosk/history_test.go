@@ -0,0 +1,39 @@
+package kiosk
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zhirsch/destinykioskstatus/db"
+)
+
+func TestNewHistory(t *testing.T) {
+	start := time.Date(2017, 3, 4, 0, 0, 0, 0, time.UTC)
+	history := NewHistory(1, "Ships", []*db.Snapshot{
+		{TakenAt: start, Items: map[uint32]bool{1: true, 2: false, 3: false}},
+		{TakenAt: start.Add(time.Hour), Items: map[uint32]bool{1: true, 2: true, 3: false}},
+	})
+
+	want := []HistoryPoint{
+		{TakenAt: start, Owned: 1, Total: 3, Percent: 33},
+		{TakenAt: start.Add(time.Hour), Owned: 2, Total: 3, Percent: 66},
+	}
+	if len(history.Progress) != len(want) {
+		t.Fatalf("got progress %+v, want %+v", history.Progress, want)
+	}
+	for i, point := range history.Progress {
+		if point != want[i] {
+			t.Errorf("got point %+v, want %+v", point, want[i])
+		}
+	}
+
+	if got := history.AcquiredAt[1]; !got.Equal(start) {
+		t.Errorf("item 1 acquired at %v, want %v", got, start)
+	}
+	if got := history.AcquiredAt[2]; !got.Equal(start.Add(time.Hour)) {
+		t.Errorf("item 2 acquired at %v, want %v", got, start.Add(time.Hour))
+	}
+	if got, ok := history.AcquiredAt[3]; ok {
+		t.Errorf("item 3 was never unlocked, but acquired at %v", got)
+	}
+}
@@ -3,7 +3,7 @@ package kiosk
 import (
 	"fmt"
 	"log"
-	"strings"
+	"sort"
 	"sync"
 	"time"
 
@@ -26,11 +26,92 @@ var vendorIdentifierBlacklist = map[string]bool{
 	"VENDOR_REEF_POSTMASTER":     true,
 }
 
+// VendorHashes are the hashes of the kiosk vendors, in display order.
+var VendorHashes = [...]uint32{
+	3301500998, // Emblems
+	2420628997, // Shaders
+	2244880194, // Ships
+	44395194,   // Sparrows
+	614738178,  // Emotes
+	1460182514, // Exotic Weapons
+	3902439767, // Exotic Armor
+}
+
+// unknownIcon is shown for items that aren't in the manifest.
+const unknownIcon = "https://www.bungie.net/img/misc/missing_icon.png"
+
 type Item struct {
-	Description string
-	Icon        string
-	Missing     bool
-	ForSale     bool
+	Hash    uint32
+	Name    string
+	Icon    string
+	Missing bool
+	ForSale bool
+
+	// Failures are the reasons that the vendor gave that the character
+	// can't buy the item.
+	Failures []Failure
+
+	// These are from the item's definition in the manifest.
+	TypeName        string
+	Tier            api.TierType
+	TierName        string
+	ItemDescription string
+	SecondaryIcon   string
+	Screenshot      string
+
+	// Sources are where the item can be obtained from.
+	Sources []Source
+
+	// Unknown is set if the item isn't in the manifest.
+	Unknown bool
+}
+
+// A Failure is a reason that the vendor gave that a character can't buy an
+// item, like not having a high enough rank.
+type Failure struct {
+	// Index is the index of Text in the vendor definition's FailureStrings.
+	Index int
+	Text  string
+}
+
+// A Source is somewhere that items can be obtained from, like a raid, an event
+// or a vendor.
+type Source struct {
+	Hash uint32
+	Name string
+}
+
+// Failed returns whether the vendor gave reasons that the character can't buy
+// the item.
+func (i Item) Failed() bool {
+	return len(i.Failures) != 0
+}
+
+// HasSource returns whether the item can be obtained from the source.
+func (i Item) HasSource(sourceHash uint32) bool {
+	for _, source := range i.Sources {
+		if source.Hash == sourceHash {
+			return true
+		}
+	}
+	return false
+}
+
+// TierClass returns the CSS class for the item's rarity.
+func (i Item) TierClass() string {
+	switch i.Tier {
+	case api.TierTypeExotic:
+		return "exotic"
+	case api.TierTypeSuperior:
+		return "legendary"
+	case api.TierTypeRare:
+		return "rare"
+	case api.TierTypeCommon:
+		return "common"
+	case api.TierTypeBasic:
+		return "basic"
+	}
+	return "unknown"
 }
 
 type Category struct {
@@ -38,10 +119,119 @@ type Category struct {
 	Items []Item
 }
 
+// A TierGroup is the items in a category that have the same rarity.
+type TierGroup struct {
+	Tier     api.TierType
+	TierName string
+	Items    []Item
+}
+
+// ByTier groups the category's items by rarity, rarest first.  The items in
+// each group are in the same order as in the category.
+func (c Category) ByTier() []TierGroup {
+	var groups []TierGroup
+	for _, item := range c.Items {
+		i := sort.Search(len(groups), func(i int) bool { return groups[i].Tier <= item.Tier })
+		if i == len(groups) || groups[i].Tier != item.Tier {
+			groups = append(groups, TierGroup{})
+			copy(groups[i+1:], groups[i:])
+			groups[i] = TierGroup{Tier: item.Tier, TierName: item.TierName}
+		}
+		groups[i].Items = append(groups[i].Items, item)
+	}
+	return groups
+}
+
 type Data struct {
-	Title      string
-	User       string
-	Categories []Category
+	VendorHash  uint32
+	CharacterID db.DestinyCharacterID
+	Title       string
+	User        string
+	Categories  []Category
+
+	// StaleManifest is set if some of the definitions weren't in the
+	// manifest, which means that it needs to be updated.
+	StaleManifest bool
+}
+
+// Sources returns the sources of all the items, sorted by name.
+func (d Data) Sources() []Source {
+	seen := make(map[uint32]bool)
+	var sources []Source
+	for _, category := range d.Categories {
+		for _, item := range category.Items {
+			for _, source := range item.Sources {
+				if !seen[source.Hash] {
+					seen[source.Hash] = true
+					sources = append(sources, source)
+				}
+			}
+		}
+	}
+	sort.Slice(sources, func(i, j int) bool { return sources[i].Name < sources[j].Name })
+	return sources
+}
+
+// FilterBySource returns a copy of the data with only the items that can be
+// obtained from the source.  Categories without any such items are removed.
+func (d Data) FilterBySource(sourceHash uint32) Data {
+	filtered := d
+	filtered.Categories = nil
+	for _, category := range d.Categories {
+		c := Category{Title: category.Title}
+		for _, item := range category.Items {
+			if item.HasSource(sourceHash) {
+				c.Items = append(c.Items, item)
+			}
+		}
+		if len(c.Items) > 0 {
+			filtered.Categories = append(filtered.Categories, c)
+		}
+	}
+	return filtered
+}
+
+// Snapshot returns which of the kiosk's items the character has unlocked.
+func (d Data) Snapshot(takenAt time.Time) *db.Snapshot {
+	snapshot := &db.Snapshot{
+		CharacterID: d.CharacterID,
+		VendorHash:  d.VendorHash,
+		TakenAt:     takenAt,
+		Items:       make(map[uint32]bool),
+	}
+	for _, category := range d.Categories {
+		c := db.SnapshotCategory{Title: category.Title}
+		for _, item := range category.Items {
+			snapshot.Items[item.Hash] = !item.Missing
+			c.ItemHashes = append(c.ItemHashes, item.Hash)
+		}
+		snapshot.Categories = append(snapshot.Categories, c)
+	}
+	return snapshot
+}
+
+// FromSnapshot returns the status of the kiosk as it was when the snapshot was
+// taken, with the strings in the manifest's locale.  Whether the items were
+// for sale isn't recorded, so ForSale is never set.
+func FromSnapshot(snapshot *db.Snapshot, manifest *api.Manifest) Data {
+	vendorDefinition, stale := getVendorDefinition(snapshot.VendorHash, manifest)
+	data := Data{
+		VendorHash:    snapshot.VendorHash,
+		CharacterID:   snapshot.CharacterID,
+		Title:         vendorDefinition.Summary.VendorName,
+		StaleManifest: stale,
+	}
+	for _, c := range snapshot.Categories {
+		category := Category{Title: c.Title}
+		for _, itemHash := range c.ItemHashes {
+			item := newItem(itemHash, manifest)
+			item.Missing = !snapshot.Items[itemHash]
+			data.StaleManifest = data.StaleManifest || item.Unknown
+			category.Items = append(category.Items, item)
+		}
+		data.Categories = append(data.Categories, category)
+	}
+	return data
 }
 
 func (d Data) MissingAndForSale() bool {
@@ -62,26 +252,30 @@ func (c Category) MissingAndForSale() bool {
 	return false
 }
 
+// FetchKioskStatus returns the status of the kiosk, with the strings in the
+// manifest's locale.
 func FetchKioskStatus(bungieUser *db.BungieUser, destinyUser *db.DestinyUser, characterID db.DestinyCharacterID, vendorHash uint32, client *api.Client, manifest *api.Manifest) Data {
 	// Get the vendor info.
-	vendorResp := client.MyCharacterVendorData(bungieUser.Token, destinyUser.MembershipType, characterID, vendorHash)
-	vendorDefinition := manifest.GetDestinyVendorDefinition(vendorHash)
+	vendorResp := client.MyCharacterVendorData(bungieUser.Token, destinyUser.MembershipType, characterID, vendorHash, manifest.Locale())
+	vendorDefinition, stale := getVendorDefinition(vendorHash, manifest)
 
 	// Get the items that are for sale for this user.
 	itemsForSale := getItemsForSale(destinyUser.MembershipType, characterID, client, manifest, bungieUser.Token)
 
 	data := Data{
-		Title: vendorDefinition.Summary.VendorName,
-		User:  destinyUser.DisplayName,
+		VendorHash:    vendorHash,
+		CharacterID:   characterID,
+		Title:         vendorDefinition.Summary.VendorName,
+		User:          destinyUser.DisplayName,
+		StaleManifest: stale,
 	}
 	for _, saleItemCategory := range vendorResp.Response.Data.SaleItemCategories {
 		category := Category{Title: saleItemCategory.CategoryTitle}
 		for _, saleItem := range saleItemCategory.SaleItems {
-			itemDefinition := manifest.GetDestinyInventoryItemDefinition(saleItem.Item.ItemHash)
-			item := Item{
-				Description: getItemDescription(itemDefinition.ItemName, saleItem.FailureIndexes, vendorDefinition.FailureStrings),
-				Icon:        fmt.Sprintf("https://www.bungie.net%s", itemDefinition.Icon),
-			}
+			item := newItem(saleItem.Item.ItemHash, manifest)
+			failures, staleFailures := getFailures(saleItem.FailureIndexes, vendorDefinition.FailureStrings)
+			item.Failures = failures
+			data.StaleManifest = data.StaleManifest || item.Unknown || staleFailures
 			for _, unlockStatus := range saleItem.UnlockStatuses {
 				item.Missing = item.Missing || !unlockStatus.IsSet
 			}
@@ -96,6 +290,93 @@ func FetchKioskStatus(bungieUser *db.BungieUser, destinyUser *db.DestinyUser, ch
 	return data
 }
 
+// getVendorDefinition returns the vendor's definition from the manifest, or a
+// placeholder and true if the vendor isn't in the manifest.
+func getVendorDefinition(vendorHash uint32, manifest *api.Manifest) (*api.DestinyVendorDefinition, bool) {
+	vendorDefinition, err := manifest.GetDestinyVendorDefinition(vendorHash)
+	if api.IsNotFound(err) {
+		log.Printf("%v; the manifest may be stale", err)
+		vendorDefinition = &api.DestinyVendorDefinition{Hash: vendorHash}
+		vendorDefinition.Summary.VendorName = fmt.Sprintf("Unknown Vendor %v", vendorHash)
+		return vendorDefinition, true
+	} else if err != nil {
+		panic(err)
+	}
+	return vendorDefinition, false
+}
+
+// VendorName returns the name of the vendor in the manifest's locale.
+func VendorName(vendorHash uint32, manifest *api.Manifest) string {
+	vendorDefinition, _ := getVendorDefinition(vendorHash, manifest)
+	return vendorDefinition.Summary.VendorName
+}
+
+// newItem returns the item with its definition filled in from the manifest, or
+// a placeholder if the item isn't in the manifest.
+func newItem(itemHash uint32, manifest *api.Manifest) Item {
+	itemDefinition, err := manifest.GetDestinyInventoryItemDefinition(itemHash)
+	if api.IsNotFound(err) {
+		log.Printf("%v; the manifest may be stale", err)
+		return Item{
+			Hash:    itemHash,
+			Name:    fmt.Sprintf("Unknown Item %v", itemHash),
+			Icon:    unknownIcon,
+			Unknown: true,
+		}
+	} else if err != nil {
+		panic(err)
+	}
+	var sources []Source
+	for _, sourceHash := range itemDefinition.SourceHashes {
+		sourceDefinition, err := manifest.GetDestinyRewardSourceDefinition(sourceHash)
+		if api.IsNotFound(err) {
+			log.Printf("%v; the manifest may be stale", err)
+			continue
+		} else if err != nil {
+			panic(err)
+		}
+		sources = append(sources, Source{Hash: sourceHash, Name: sourceDefinition.SourceName})
+	}
+	return Item{
+		Hash:            itemHash,
+		Name:            itemDefinition.ItemName,
+		Icon:            bungieURL(itemDefinition.Icon),
+		TypeName:        itemDefinition.ItemTypeName,
+		Tier:            itemDefinition.TierType,
+		TierName:        itemDefinition.TierTypeName,
+		ItemDescription: itemDefinition.ItemDescription,
+		SecondaryIcon:   bungieURL(itemDefinition.SecondaryIcon),
+		Screenshot:      bungieURL(itemDefinition.Screenshot),
+		Sources:         sources,
+	}
+}
+
+// bungieURL returns the URL of a path on bungie.net, or "" if path is "".
+func bungieURL(path string) string {
+	if path == "" {
+		return ""
+	}
+	return fmt.Sprintf("https://www.bungie.net%s", path)
+}
+
+// FetchAllKioskStatus fetches the status of every kiosk in VendorHashes.
+func FetchAllKioskStatus(bungieUser *db.BungieUser, destinyUser *db.DestinyUser, client *api.Client, manifest *api.Manifest) []Data {
+	// Get the first character.  This assumes that the kiosk is the same for
+	// all characters.  Probably not a bad assumption when considering
+	// ships, shaders, sparrows, emblems, etc. for sale.
+	return FetchCharacterKioskStatus(bungieUser, destinyUser, destinyUser.DestinyCharacters[0].CharacterID, client, manifest)
+}
+
+// FetchCharacterKioskStatus fetches the status of every kiosk in VendorHashes
+// for the character.
+func FetchCharacterKioskStatus(bungieUser *db.BungieUser, destinyUser *db.DestinyUser, characterID db.DestinyCharacterID, client *api.Client, manifest *api.Manifest) []Data {
+	var data []Data
+	for _, vendorHash := range VendorHashes {
+		data = append(data, FetchKioskStatus(bungieUser, destinyUser, characterID, vendorHash, client, manifest))
+	}
+	return data
+}
+
 func getItemsForSale(membershipType db.DestinyMembershipType, characterID db.DestinyCharacterID, client *api.Client, manifest *api.Manifest, token *oauth2.Token) map[uint32]bool {
 	allVendorsResp := client.GetAllVendorsForCurrentCharacter(token, membershipType, characterID)
 
@@ -104,7 +385,15 @@ func getItemsForSale(membershipType db.DestinyMembershipType, characterID db.Des
 		if !vendor.Enabled {
 			continue
 		}
-		vendorDefinition := manifest.GetDestinyVendorDefinition(vendor.VendorHash)
+		vendorDefinition, err := manifest.GetDestinyVendorDefinition(vendor.VendorHash)
+		if api.IsNotFound(err) {
+			// Without the definition, it's not possible to tell whether
+			// the vendor is blacklisted.
+			log.Printf("skipping vendor: %v; the manifest may be stale", err)
+			continue
+		} else if err != nil {
+			panic(err)
+		}
 		if _, ok := vendorIdentifierBlacklist[vendorDefinition.Summary.VendorIdentifier]; ok {
 			continue
 		}
@@ -118,15 +407,23 @@ func getItemsForSale(membershipType db.DestinyMembershipType, characterID db.Des
 	return forSale
 }
 
-func getItemDescription(itemName string, failureIndexes []int, failureStrings []string) string {
-	if len(failureIndexes) == 0 {
-		return itemName
-	}
-	var description []string
+// getFailures returns the failure reasons with the given indexes into the
+// vendor definition's failureStrings.  An index that's out of range gets a
+// reason without any text, and true is returned since the manifest is stale.
+func getFailures(failureIndexes []int, failureStrings []string) ([]Failure, bool) {
+	var failures []Failure
+	stale := false
 	for _, failureIndex := range failureIndexes {
-		description = append(description, failureStrings[failureIndex])
+		failure := Failure{Index: failureIndex}
+		if failureIndex >= 0 && failureIndex < len(failureStrings) {
+			failure.Text = failureStrings[failureIndex]
+		} else {
+			stale = true
+			log.Printf("failure index %v is out of range of %v failure strings; the manifest may be stale", failureIndex, len(failureStrings))
+		}
+		failures = append(failures, failure)
 	}
-	return itemName + "\n\n" + strings.Join(description, "\n")
+	return failures, stale
 }
 
 type cache struct {
@@ -145,7 +442,8 @@ func (c *cache) get(client *api.Client, manifest *api.Manifest, token *oauth2.To
 		vendorResp, ok = c.entries[vendorDefinition.Hash]
 		if !ok || c.isExpired(vendorResp) {
 			log.Printf("getting vendor %v (%v)", vendorDefinition.Summary.VendorName, vendorDefinition.Summary.VendorIdentifier)
-			vendorResp = client.MyCharacterVendorData(token, membershipType, characterID, vendorDefinition.Hash)
+			// Only the item hashes are used, so the locale doesn't matter.
+			vendorResp = client.MyCharacterVendorData(token, membershipType, characterID, vendorDefinition.Hash, "")
 			if c.entries == nil {
 				c.entries = make(map[uint32]*api.MyCharacterVendorDataResponse)
 			}
@@ -0,0 +1,131 @@
+package kiosk
+
+import (
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/zhirsch/destinykioskstatus/api"
+	"github.com/zhirsch/destinykioskstatus/db"
+)
+
+// A SearchResult is an item that matched a search.
+type SearchResult struct {
+	VendorHash uint32
+	Vendor     string
+	Category   string
+	Item       Item
+}
+
+// searchIndex maps the names of the items sold by the kiosks to the vendors
+// that sell them.
+type searchIndex struct {
+	version string
+	entries []searchEntry
+}
+
+type searchEntry struct {
+	name       string // lowercase
+	itemHash   uint32
+	vendorHash uint32
+}
+
+// buildSearchIndex indexes the items in the definitions of the kiosks in
+// VendorHashes.
+func buildSearchIndex(manifest *api.Manifest) *searchIndex {
+	index := &searchIndex{version: manifest.Version()}
+	for _, vendorHash := range VendorHashes {
+		vendorDefinition, err := manifest.GetDestinyVendorDefinition(vendorHash)
+		if api.IsNotFound(err) {
+			log.Printf("not indexing vendor: %v; the manifest may be stale", err)
+			continue
+		} else if err != nil {
+			panic(err)
+		}
+		for _, sale := range vendorDefinition.Sales {
+			itemDefinition, err := manifest.GetDestinyInventoryItemDefinition(sale.Item.ItemHash)
+			if api.IsNotFound(err) {
+				continue
+			} else if err != nil {
+				panic(err)
+			}
+			index.entries = append(index.entries, searchEntry{
+				name:       strings.ToLower(itemDefinition.ItemName),
+				itemHash:   sale.Item.ItemHash,
+				vendorHash: vendorHash,
+			})
+		}
+	}
+	log.Printf("indexed %v kiosk items in manifest %v (%v)", len(index.entries), manifest.Version(), manifest.Locale())
+	return index
+}
+
+// lookup returns the hashes of the items whose names contain query, grouped by
+// the vendor that sells them.
+func (index *searchIndex) lookup(query string) map[uint32]map[uint32]bool {
+	query = strings.ToLower(query)
+	matches := make(map[uint32]map[uint32]bool)
+	for _, entry := range index.entries {
+		if !strings.Contains(entry.name, query) {
+			continue
+		}
+		if matches[entry.vendorHash] == nil {
+			matches[entry.vendorHash] = make(map[uint32]bool)
+		}
+		matches[entry.vendorHash][entry.itemHash] = true
+	}
+	return matches
+}
+
+type searchIndexCache struct {
+	// There's an index for each manifest, since each locale has its own
+	// names.  Each index is rebuilt when its manifest is reloaded.
+	indexes map[*api.Manifest]*searchIndex
+	sync.Mutex
+}
+
+func (c *searchIndexCache) get(manifest *api.Manifest) *searchIndex {
+	c.Lock()
+	defer c.Unlock()
+	index, ok := c.indexes[manifest]
+	if !ok || index.version != manifest.Version() {
+		index = buildSearchIndex(manifest)
+		if c.indexes == nil {
+			c.indexes = make(map[*api.Manifest]*searchIndex)
+		}
+		c.indexes[manifest] = index
+	}
+	return index
+}
+
+var searchIndexes searchIndexCache
+
+// Search returns the items sold by the kiosks whose names contain query, with
+// their status for the character.
+func Search(bungieUser *db.BungieUser, destinyUser *db.DestinyUser, characterID db.DestinyCharacterID, query string, client *api.Client, manifest *api.Manifest) []SearchResult {
+	matches := searchIndexes.get(manifest).lookup(query)
+
+	var results []SearchResult
+	for _, vendorHash := range VendorHashes {
+		itemHashes, ok := matches[vendorHash]
+		if !ok {
+			continue
+		}
+		data := FetchKioskStatus(bungieUser, destinyUser, characterID, vendorHash, client, manifest)
+		for _, category := range data.Categories {
+			for _, item := range category.Items {
+				if itemHashes[item.Hash] {
+					results = append(results, SearchResult{
+						VendorHash: vendorHash,
+						Vendor:     data.Title,
+						Category:   category.Title,
+						Item:       item,
+					})
+				}
+			}
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Item.Name < results[j].Item.Name })
+	return results
+}
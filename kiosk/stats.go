@@ -0,0 +1,85 @@
+package kiosk
+
+// Stats are the completion statistics of a group of items.
+type Stats struct {
+	Owned             int
+	Total             int
+	MissingAndForSale int
+
+	// Percent is the percentage of the items that are owned, rounded down
+	// so that it's only 100 when every item is owned.
+	Percent int
+}
+
+func (s *Stats) add(item Item) {
+	s.Total++
+	if !item.Missing {
+		s.Owned++
+	} else if item.ForSale {
+		s.MissingAndForSale++
+	}
+	s.Percent = s.Owned * 100 / s.Total
+}
+
+// merge adds the items counted by other.
+func (s *Stats) merge(other Stats) {
+	s.Owned += other.Owned
+	s.Total += other.Total
+	s.MissingAndForSale += other.MissingAndForSale
+	if s.Total > 0 {
+		s.Percent = s.Owned * 100 / s.Total
+	}
+}
+
+// Stats returns the completion statistics of the category.
+func (c Category) Stats() Stats {
+	var stats Stats
+	for _, item := range c.Items {
+		stats.add(item)
+	}
+	return stats
+}
+
+// Stats returns the completion statistics of the kiosk.
+func (d Data) Stats() Stats {
+	var stats Stats
+	for _, category := range d.Categories {
+		stats.merge(category.Stats())
+	}
+	return stats
+}
+
+// A Summary is the completion statistics of a kiosk and its categories,
+// without the items.
+type Summary struct {
+	VendorHash uint32
+	Title      string
+	Stats
+	Categories []CategorySummary
+}
+
+type CategorySummary struct {
+	Title string
+	Stats
+}
+
+func (d Data) Summary() Summary {
+	summary := Summary{
+		VendorHash: d.VendorHash,
+		Title:      d.Title,
+		Stats:      d.Stats(),
+	}
+	for _, category := range d.Categories {
+		summary.Categories = append(summary.Categories, CategorySummary{category.Title, category.Stats()})
+	}
+	return summary
+}
+
+// TotalStats returns the completion statistics of all the kiosks.
+func TotalStats(data []Data) Stats {
+	var stats Stats
+	for _, d := range data {
+		stats.merge(d.Stats())
+	}
+	return stats
+}
@@ -0,0 +1,29 @@
+package kiosk
+
+import (
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	data := []Data{
+		{Categories: []Category{
+			{Items: []Item{{Hash: 1}, {Hash: 2, Missing: true, ForSale: true}}},
+			{Items: []Item{{Hash: 3, Missing: true}}},
+		}},
+		{Categories: []Category{
+			{Items: []Item{{Hash: 4}}},
+			{},
+		}},
+		{},
+	}
+
+	if got, want := data[0].Stats(), (Stats{Owned: 1, Total: 3, MissingAndForSale: 1, Percent: 33}); got != want {
+		t.Errorf("got kiosk stats %+v, want %+v", got, want)
+	}
+	if got, want := data[2].Stats(), (Stats{}); got != want {
+		t.Errorf("got empty kiosk stats %+v, want %+v", got, want)
+	}
+	if got, want := TotalStats(data), (Stats{Owned: 2, Total: 4, MissingAndForSale: 1, Percent: 50}); got != want {
+		t.Errorf("got total stats %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,26 @@
+package kiosk
+
+import (
+	"github.com/zhirsch/destinykioskstatus/db"
+)
+
+// Wishlisted returns the items on the wishlist that the kiosks sell, with their
+// status.  Items that none of the kiosks sell are left out.
+func Wishlisted(kiosks []Data, wishlist *db.Wishlist) []SearchResult {
+	var results []SearchResult
+	for _, data := range kiosks {
+		for _, category := range data.Categories {
+			for _, item := range category.Items {
+				if wishlist.Contains(item.Hash) {
+					results = append(results, SearchResult{
+						VendorHash: data.VendorHash,
+						Vendor:     data.Title,
+						Category:   category.Title,
+						Item:       item,
+					})
+				}
+			}
+		}
+	}
+	return results
+}
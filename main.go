@@ -4,11 +4,20 @@ import (
 	"flag"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/zhirsch/oauth2"
 	"github.com/zhirsch/oauth2/bungie"
 
+	"github.com/zhirsch/destinykioskstatus/api"
+	"github.com/zhirsch/destinykioskstatus/db"
+	"github.com/zhirsch/destinykioskstatus/email"
 	"github.com/zhirsch/destinykioskstatus/handler"
+	"github.com/zhirsch/destinykioskstatus/kiosk"
 	"github.com/zhirsch/destinykioskstatus/server"
 )
 
@@ -16,12 +25,25 @@ var (
 	addr           = flag.String("addr", ":443", "The address to listen on.")
 	apiKey         = flag.String("apikey", "", "The Bungie API key.")
 	authURL        = flag.String("authurl", "", "The Bungie auth URL.")
-	manifestDBPath = flag.String("manifestdb", "", "The path to the manifest sqlite database.")
-	userDBPath     = flag.String("userdb", "", "The path to the user sqlite database.")
+	manifestDBPath = flag.String("manifestdb", "", "The path to the manifest sqlite database for --manifestlocale.")
+	manifestLocale = flag.String("manifestlocale", "en", "The locale of --manifestdb, used when there isn't a manifest for the user's language.")
+	manifestDBs    = flag.String("manifestdbs", "", "Comma-separated locale=path pairs of manifest sqlite databases for other locales.")
+	manifestCache  = flag.Int("manifestcache", 0, "The number of manifest definitions to cache.  Zero uses the default; negative disables the cache.")
+	manifestWarmUp = flag.Bool("manifestwarmup", false, "Load the definitions of the kiosk items into the cache at startup.")
+	manifestPoll   = flag.Duration("manifestpoll", time.Minute, "How often to check whether the manifest database has changed.  Zero disables polling; SIGHUP always reloads it.")
+	userDSN        = flag.String("userdb", "", "The user database: the path of a SQLite database, or a postgres:// URL.")
+	tokenKeys      = flag.String("tokenkeys", "", "Comma-separated id=key pairs of the keys that encrypt OAuth tokens in the user database.  The first key is used for new tokens.  Overrides --tokenkeysfile and $"+db.TokenKeysEnv+".")
+	tokenKeysFile  = flag.String("tokenkeysfile", "", "The path to a file containing --tokenkeys.")
 	templatePath   = flag.String("template", "kiosk.html", "The path to the HTML template file.")
+	notifyTmplPath = flag.String("notifytemplate", "notify.html", "The path to the notification HTML template file.")
 	tlsCertPath    = flag.String("tlscert", "server.crt", "The path to the  TLS certificate file.")
 	tlsKeyPath     = flag.String("tlskey", "server.key", "The path to the TLS key file.")
 	mediaPath      = flag.String("media", "", "The path to the media directory.")
+	signingKey     = flag.String("signingkey", "", "The key used to sign links sent to users.")
+	sendGridAPIKey = flag.String("sendgridapikey", "", "The SendGrid API key.  If empty, emails are logged instead of sent.")
+	sendGridHost   = flag.String("sendgridhost", "https://api.sendgrid.com", "The SendGrid host.")
+	fromName       = flag.String("fromname", "Destiny Kiosk Status", "The from name for emails.")
+	fromAddr       = flag.String("fromaddr", "noreply@destinykioskstatus.com", "The from email for emails.")
 )
 
 func main() {
@@ -35,15 +57,26 @@ func main() {
 	if *manifestDBPath == "" {
 		log.Fatal("need to provide --manifestdb")
 	}
-	if *userDBPath == "" {
+	if *userDSN == "" {
 		log.Fatal("need to provide --userdb")
 	}
 	if *templatePath == "" {
 		log.Fatal("need to provide --template")
 	}
+	if *notifyTmplPath == "" {
+		log.Fatal("need to provide --notifytemplate")
+	}
 	if *mediaPath == "" {
 		log.Fatal("need to provide --media")
 	}
+	if *signingKey == "" {
+		log.Fatal("need to provide --signingkey")
+	}
+
+	keyring, err := db.LoadKeyring(*tokenKeys, *tokenKeysFile)
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	authConfig := &oauth2.Config{
 		ClientID:  *apiKey,
@@ -51,14 +84,47 @@ func main() {
 		Exchanger: bungie.Exchanger{},
 	}
 
-	s, err := server.NewServer(authConfig, *manifestDBPath, *userDBPath, *templatePath)
+	var sender *email.Sender
+	if *sendGridAPIKey != "" {
+		sender = &email.Sender{
+			APIKey:   *sendGridAPIKey,
+			Host:     *sendGridHost,
+			FromName: *fromName,
+			FromAddr: *fromAddr,
+		}
+	}
+
+	manifestDBPaths, err := api.ParseLocalePaths(*manifestDBs)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// The locales of the other manifests are lowercase, so this one must
+	// be too to match them.
+	locale := strings.ToLower(*manifestLocale)
+	manifestDBPaths[locale] = *manifestDBPath
+
+	manifestOpts := api.ManifestOptions{CacheSize: *manifestCache}
+	if *manifestWarmUp {
+		manifestOpts.WarmUpVendors = kiosk.VendorHashes[:]
+	}
+
+	s, err := server.NewServer(authConfig, locale, manifestDBPaths, manifestOpts, *userDSN, keyring, *templatePath, *notifyTmplPath, *signingKey, sender)
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	// Pick up new manifest databases without restarting.
+	if *manifestPoll > 0 {
+		go s.Manifests.Watch(*manifestPoll)
+	}
+	go reloadManifestsOnSIGHUP(s.Manifests)
+
 	handlers := map[string]http.Handler{
 		"/BungieAuthCallback": handler.BungieAuthCallbackHandler{s, authConfig},
 		"/media/":             http.StripPrefix("/media/", http.FileServer(http.Dir(*mediaPath))),
+		"/unsubscribe":        handler.UnsubscribeHandler{s},
+		"/verify":             handler.VerifyEmailHandler{s},
+		"/shared":             handler.SharedHandler{s},
 	}
 	authedHandlers := map[string]handler.Handler{
 		"/emblems":  handler.VendorHandler{s, 3301500998},
@@ -68,7 +134,22 @@ func main() {
 		"/emotes":   handler.VendorHandler{s, 614738178},
 		"/weapons":  handler.VendorHandler{s, 1460182514},
 		"/armor":    handler.VendorHandler{s, 3902439767},
+
+		"/notifications/preview": handler.NotificationPreviewHandler{s},
+		"/settings":              handler.SettingsHandler{s},
+		"/search":                handler.SearchHandler{s},
+		"/share":                 handler.ShareHandler{s},
+		"/wishlist":              handler.WishlistHandler{s},
 	}
+	vendorPaths := make(map[uint32]string)
+	for p, h := range authedHandlers {
+		if h, ok := h.(handler.VendorHandler); ok {
+			vendorPaths[h.VendorHash] = p
+		}
+	}
+	authedHandlers["/overview"] = handler.OverviewHandler{s, vendorPaths}
+	authedHandlers["/history"] = handler.HistoryHandler{s, vendorPaths}
+	authedHandlers["/friend"] = handler.FriendHandler{s, vendorPaths}
 	for p, h := range authedHandlers {
 		handlers[p] = handler.AuthenticationMiddlewareHandler{s, authConfig, h}
 	}
@@ -80,3 +161,11 @@ func main() {
 		log.Fatal(err)
 	}
 }
+
+func reloadManifestsOnSIGHUP(manifests *api.Manifests) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGHUP)
+	for range c {
+		manifests.Reload()
+	}
+}
@@ -3,55 +3,58 @@ package main
 import (
 	"bytes"
 	"flag"
-	"fmt"
 	"html/template"
+	"io/ioutil"
 	"log"
+	"os"
+	"strings"
+	texttemplate "text/template"
 	"time"
 
-	sendgrid "github.com/sendgrid/sendgrid-go"
-	"github.com/sendgrid/sendgrid-go/helpers/mail"
 	"github.com/zhirsch/destinykioskstatus/api"
 	"github.com/zhirsch/destinykioskstatus/db"
+	"github.com/zhirsch/destinykioskstatus/email"
 	"github.com/zhirsch/destinykioskstatus/kiosk"
+	"github.com/zhirsch/destinykioskstatus/sign"
 	"github.com/zhirsch/oauth2"
 	"github.com/zhirsch/oauth2/bungie"
 )
 
-const (
-	sendGridEndpoint = "/v3/mail/send"
-)
-
 var (
-	vendorHashes = [...]uint32{
-		3301500998,
-		2420628997,
-		2244880194,
-		44395194,
-		614738178,
-		1460182514,
-		3902439767,
-	}
-)
+	fromName         = flag.String("from_name", "Destiny Kiosk Status", "The from name.")
+	fromAddr         = flag.String("from_addr", "noreply@destinykioskstatus.com", "The from email.")
+	templatePath     = flag.String("template", "notify.html", "The path to the HTML template file.")
+	textTemplatePath = flag.String("text_template", "notify.txt", "The path to the plain text template file.")
+	baseURL          = flag.String("base_url", "https://destinykioskstatus.com", "The base URL of the server, used for links in the email.")
+	signingKey       = flag.String("signing_key", "", "The key used to sign links in the email.  Must match the server's --signingkey.")
 
-var (
-	fromName     = flag.String("from_name", "Destiny Kiosk Status", "The from name.")
-	fromAddr     = flag.String("from_addr", "noreply@destinykioskstatus.com", "The from email.")
-	templatePath = flag.String("template", "notify.html", "The path to the HTML template file.")
+	dryRun       = flag.Bool("dry_run", false, "Render the email instead of sending it.")
+	dryRunOutput = flag.String("dry_run_output", "", "The path to write the rendered email to when --dry_run is set.  Defaults to stdout.")
+	dryRunText   = flag.Bool("dry_run_text", false, "Render the plain text part instead of the HTML part when --dry_run is set.")
+	wishlistOnly = flag.Bool("wishlist_only", false, "Only send the alert about items on the wishlist that are for sale, not the kiosk status update.  Lets the notifier run often without sending the update each time.")
 
 	sendGridAPIKey = flag.String("sendgrid_apikey", "", "The SendGrid API key.")
 	sendGridHost   = flag.String("sendgrid_host", "", "The SendGrid host.")
 
 	bungieAPIKey         = flag.String("bungie_apikey", "", "The Bungie API key.")
 	bungieAuthURL        = flag.String("bungie_authurl", "", "The Bungie auth URL.")
-	bungieManifestDBPath = flag.String("bungie_manifestdb", "", "The path to the Bungie manifest db.")
-	userDBPath           = flag.String("userdb", "", "The path to the user sqlite database.")
+	bungieManifestDBPath = flag.String("bungie_manifestdb", "", "The path to the Bungie manifest db for --bungie_manifestlocale.")
+	bungieManifestLocale = flag.String("bungie_manifestlocale", "en", "The locale of --bungie_manifestdb, used when there isn't a manifest for the user's language.")
+	bungieManifestDBs    = flag.String("bungie_manifestdbs", "", "Comma-separated locale=path pairs of Bungie manifest dbs for other locales.")
+	userDSN              = flag.String("userdb", "", "The user database: the path of a SQLite database, or a postgres:// URL.")
+	tokenKeys            = flag.String("token_keys", "", "Comma-separated id=key pairs of the keys that encrypt OAuth tokens in the user database.  Overrides --token_keys_file and $"+db.TokenKeysEnv+".")
+	tokenKeysFile        = flag.String("token_keys_file", "", "The path to a file containing --token_keys.")
+	bungieMembershipID   = flag.String("bungie_membershipid", "12646688", "The Bungie membership ID of the user to notify.")
 )
 
 func main() {
 	flag.Parse()
-	if *sendGridAPIKey == "" {
+	if *sendGridAPIKey == "" && !*dryRun {
 		log.Fatal("need to provide --sendgrid_apikey")
 	}
+	if *signingKey == "" {
+		log.Fatal("need to provide --signing_key")
+	}
 	if *bungieAPIKey == "" {
 		log.Fatal("need to provide --bungie_apikey")
 	}
@@ -61,7 +64,7 @@ func main() {
 	if *bungieManifestDBPath == "" {
 		log.Fatal("need to provide --bungie_manifestdb")
 	}
-	if *userDBPath == "" {
+	if *userDSN == "" {
 		log.Fatal("need to provide --userdb")
 	}
 
@@ -71,71 +74,221 @@ func main() {
 		Endpoint:  bungie.Endpoint(*bungieAuthURL),
 		Exchanger: bungie.Exchanger{},
 	}
-	client := &api.Client{authConfig}
+	client := &api.Client{AuthConfig: authConfig}
 
-	// Load the Bungie manifest.
-	manifest, err := api.NewManifest(*bungieManifestDBPath)
+	// Load the Bungie manifests.
+	manifestDBPaths, err := api.ParseLocalePaths(*bungieManifestDBs)
+	if err != nil {
+		log.Fatal(err)
+	}
+	locale := strings.ToLower(*bungieManifestLocale)
+	manifestDBPaths[locale] = *bungieManifestDBPath
+	manifests, err := api.NewManifests(locale, manifestDBPaths, api.ManifestOptions{})
 	if err != nil {
 		panic(err)
 	}
 
 	// Load the user database.
-	db, err := db.NewDB(*userDBPath)
+	keyring, err := db.LoadKeyring(*tokenKeys, *tokenKeysFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	userDB, err := db.Open(*userDSN, keyring)
 	if err != nil {
 		panic(err)
 	}
 
-	// Load the email template.
+	// Create the signer for links in the email.
+	signer, err := sign.NewSigner([]byte(*signingKey))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Load the email templates.
 	templ, err := template.ParseFiles(*templatePath)
 	if err != nil {
 		panic(err)
 	}
+	textTempl, err := texttemplate.ParseFiles(*textTemplatePath)
+	if err != nil {
+		panic(err)
+	}
 
 	// Select the Bungie user.
 	//
 	// TODO(zhirsch): Select all bungie users in a for loop.
-	bungieUser, err := db.SelectBungieUser("12646688")
+	bungieUser, err := userDB.SelectBungieUser(db.BungieMembershipID(*bungieMembershipID))
+	if err != nil {
+		panic(err)
+	}
+
+	// Skip users that have unsubscribed from all emails.
+	subscription, err := userDB.SelectSubscription(bungieUser.MembershipID)
+	if err != nil {
+		panic(err)
+	}
+	if subscription.Unsubscribed {
+		log.Printf("%v has unsubscribed", bungieUser.MembershipID)
+		return
+	}
+
+	// Only send to verified addresses.
+	to, err := userDB.SelectEmail(bungieUser.MembershipID)
+	if err != nil {
+		panic(err)
+	}
+	if !*dryRun && (to == nil || !to.Verified) {
+		log.Printf("%v doesn't have a verified email", bungieUser.MembershipID)
+		return
+	}
+
+	// Render the email in the user's preferred language.
+	preferences, err := userDB.SelectPreferences(bungieUser.MembershipID)
 	if err != nil {
 		panic(err)
 	}
+	manifest := manifests.Get(manifests.Match(preferences.Language))
 
 	// Get the Destiny user.
 	//
 	// TODO: Support multiple DestinyUsers on the same BungieUser.
 	destinyUser := bungieUser.DestinyUsers[0]
 
-	// Get the first character.  This assumes that the kiosk is the same for
-	// all characters.  Probably not a bad assumption when considering
-	// ships, shaders, sparrows, emblems, etc. for sale.
-	characterID := destinyUser.DestinyCharacters[0].CharacterID
+	// Get the kiosks, and record the collection history.
+	kiosks := kiosk.FetchAllKioskStatus(bungieUser, destinyUser, client, manifest)
+	saveSnapshots(userDB, kiosks)
 
-	// Iterate over all the vendor hashes.
-	var data []kiosk.Data
-	for _, vendorHash := range vendorHashes {
-		data = append(data, kiosk.FetchKioskStatus(bungieUser, destinyUser, characterID, vendorHash, client, manifest))
+	sender := &email.Sender{
+		APIKey:   *sendGridAPIKey,
+		Host:     *sendGridHost,
+		FromName: *fromName,
+		FromAddr: *fromAddr,
 	}
+	links := email.Links{BaseURL: *baseURL, Signer: signer}
 
-	from := mail.NewEmail(*fromName, *fromAddr)
-	subject := time.Now().Format("Destiny Kiosk Status Update for 2006-01-02")
-	to := mail.NewEmail("", "zhirsch@umich.edu")
+	// Alert the user about the items on their wishlist that are for sale,
+	// unless they've unsubscribed from the alerts.
+	wishlistAlerts := subscription.Subscribed(db.WishlistAlerts)
+	wishlist, err := userDB.SelectWishlist(bungieUser.MembershipID)
+	if err != nil {
+		panic(err)
+	}
+	var wishlisted []kiosk.SearchResult
+	if len(wishlist.Alerted) != 0 && wishlistAlerts {
+		// Some items, like class armor, are only sold to some characters,
+		// so look at the kiosks of every character.
+		allKiosks := append([]kiosk.Data(nil), kiosks...)
+		for _, character := range destinyUser.DestinyCharacters[1:] {
+			characterKiosks := kiosk.FetchCharacterKioskStatus(bungieUser, destinyUser, character.CharacterID, client, manifest)
+			saveSnapshots(userDB, characterKiosks)
+			allKiosks = append(allKiosks, characterKiosks...)
+		}
+		wishlisted = kiosk.Wishlisted(allKiosks, wishlist)
+	}
+	alert := email.NewWishlistAlert(wishlisted, wishlist, bungieUser.MembershipID, links)
+	if len(alert.Items) != 0 {
+		address := ""
+		if to != nil {
+			address = to.Address
+		}
+		msg, err := alert.Message(address)
+		if err != nil {
+			panic(err)
+		}
+		if *dryRun {
+			if *wishlistOnly {
+				body := msg.HTML
+				if *dryRunText {
+					body = msg.Text
+				}
+				if err := writeDryRun([]byte(body)); err != nil {
+					log.Fatal(err)
+				}
+			}
+		} else if err := sender.Send(msg); err != nil {
+			log.Fatal(err)
+		} else {
+			log.Printf("sent wishlist alert to %v", bungieUser.MembershipID)
+		}
+	}
+	if !*dryRun && wishlistAlerts {
+		updateWishlistAlerted(userDB, bungieUser.MembershipID, wishlist, wishlisted)
+	}
+	if *wishlistOnly {
+		return
+	}
 
-	buf := new(bytes.Buffer)
-	if err := templ.Execute(buf, data); err != nil {
+	// Render the email.
+	data := email.NewData(kiosks, bungieUser.MembershipID, subscription, links)
+	htmlBuf := new(bytes.Buffer)
+	if err := templ.Execute(htmlBuf, data); err != nil {
+		panic(err)
+	}
+	textBuf := new(bytes.Buffer)
+	if err := textTempl.Execute(textBuf, data); err != nil {
 		panic(err)
 	}
-	content := mail.NewContent("text/html", buf.String())
 
-	m := mail.NewV3MailInit(from, subject, to, content)
+	if *dryRun {
+		buf := htmlBuf
+		if *dryRunText {
+			buf = textBuf
+		}
+		if err := writeDryRun(buf.Bytes()); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 
-	request := sendgrid.GetRequest(*sendGridAPIKey, sendGridEndpoint, *sendGridHost)
-	request.Method = "POST"
-	request.Body = mail.GetRequestBody(m)
-	response, err := sendgrid.API(request)
-	if err != nil {
-		log.Println(err)
-	} else {
-		fmt.Println(response.StatusCode)
-		fmt.Println(response.Body)
-		fmt.Println(response.Headers)
+	msg := &email.Message{
+		To:      to.Address,
+		Subject: time.Now().Format("Destiny Kiosk Status Update for 2006-01-02"),
+		Text:    textBuf.String(),
+		HTML:    htmlBuf.String(),
+		Headers: map[string]string{
+			"List-Unsubscribe":      "<" + data.UnsubscribeURL + ">",
+			"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+		},
+	}
+	if err := sender.Send(msg); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("sent email to %v", bungieUser.MembershipID)
+}
+
+// saveSnapshots records the collection history of the kiosks.
+func saveSnapshots(userDB db.Storage, kiosks []kiosk.Data) {
+	now := time.Now()
+	for _, k := range kiosks {
+		if _, err := userDB.InsertSnapshot(k.Snapshot(now)); err != nil {
+			log.Printf("failed to save snapshot of %v for %v: %v", k.VendorHash, k.CharacterID, err)
+		}
+	}
+}
+
+// updateWishlistAlerted records that the user was alerted about the items on
+// their wishlist that are missing and for sale, and forgets the alerts about
+// the items that aren't anymore so that the user is alerted the next time
+// they're sold.
+func updateWishlistAlerted(userDB db.Storage, membershipID db.BungieMembershipID, wishlist *db.Wishlist, wishlisted []kiosk.SearchResult) {
+	forSale := make(map[uint32]bool)
+	for _, result := range wishlisted {
+		forSale[result.Item.Hash] = forSale[result.Item.Hash] || (result.Item.Missing && result.Item.ForSale)
+	}
+	for itemHash, alerted := range wishlist.Alerted {
+		if alerted == forSale[itemHash] {
+			continue
+		}
+		if err := userDB.UpdateWishlistAlerted(membershipID, itemHash, forSale[itemHash]); err != nil {
+			log.Printf("failed to update wishlist alert for %v: %v", itemHash, err)
+		}
+	}
+}
+
+func writeDryRun(body []byte) error {
+	if *dryRunOutput == "" {
+		_, err := os.Stdout.Write(body)
+		return err
 	}
+	return ioutil.WriteFile(*dryRunOutput, body, 0644)
 }
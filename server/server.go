@@ -7,27 +7,36 @@ import (
 
 	"github.com/zhirsch/destinykioskstatus/api"
 	"github.com/zhirsch/destinykioskstatus/db"
+	"github.com/zhirsch/destinykioskstatus/email"
+	"github.com/zhirsch/destinykioskstatus/sign"
 )
 
 type Server struct {
-	API      *api.Client
-	Manifest *api.Manifest
-	Template *template.Template
-	DB       *db.DB
+	API       *api.Client
+	Manifests *api.Manifests
+	Template  *template.Template
+	DB        db.Storage
+
+	NotifyTemplate *template.Template
+	Signer         *sign.Signer
+
+	// Sender sends emails.  If it is nil, emails are logged instead.
+	Sender *email.Sender
 }
 
-func NewServer(authConfig *oauth2.Config, manifestDBPath, userDBPath, templatePath string) (*Server, error) {
+func NewServer(authConfig *oauth2.Config, defaultLocale string, manifestDBPaths map[string]string, manifestOpts api.ManifestOptions, userDSN string, keyring *db.Keyring, templatePath, notifyTemplatePath, signingKey string, sender *email.Sender) (*Server, error) {
 	s := &Server{
-		API: &api.Client{authConfig},
+		API:    &api.Client{AuthConfig: authConfig},
+		Sender: sender,
 	}
 
-	if m, err := api.NewManifest(manifestDBPath); err != nil {
+	if ms, err := api.NewManifests(defaultLocale, manifestDBPaths, manifestOpts); err != nil {
 		panic(err)
 	} else {
-		s.Manifest = m
+		s.Manifests = ms
 	}
 
-	if db, err := db.NewDB(userDBPath); err != nil {
+	if db, err := db.Open(userDSN, keyring); err != nil {
 		panic(err)
 	} else {
 		s.DB = db
@@ -39,5 +48,17 @@ func NewServer(authConfig *oauth2.Config, manifestDBPath, userDBPath, templatePa
 		s.Template = t
 	}
 
+	if t, err := template.ParseFiles(notifyTemplatePath); err != nil {
+		panic(err)
+	} else {
+		s.NotifyTemplate = t
+	}
+
+	if signer, err := sign.NewSigner([]byte(signingKey)); err != nil {
+		panic(err)
+	} else {
+		s.Signer = signer
+	}
+
 	return s, nil
 }
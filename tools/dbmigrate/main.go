@@ -0,0 +1,63 @@
+// Command dbmigrate shows and applies the schema migrations of the user
+// database.
+//
+// Usage:
+//
+//	dbmigrate --userdb=DSN status
+//	dbmigrate --userdb=DSN up
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/zhirsch/destinykioskstatus/db"
+)
+
+var (
+	userDSN = flag.String("userdb", "", "The user database: the path of a SQLite database, or a postgres:// URL.")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %v --userdb=DSN status|up\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if *userDSN == "" {
+		log.Fatal("need to provide --userdb")
+	}
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	switch flag.Arg(0) {
+	case "status":
+		printStatus()
+	case "up":
+		if err := db.Migrate(*userDSN); err != nil {
+			log.Fatal(err)
+		}
+		printStatus()
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+func printStatus() {
+	statuses, err := db.Status(*userDSN)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, status := range statuses {
+		applied := "pending"
+		if status.Applied {
+			applied = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Printf("%3d  %-30v  %v\n", status.Version, status.Description, applied)
+	}
+}
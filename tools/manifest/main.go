@@ -0,0 +1,253 @@
+// Command manifest looks up definitions in the Destiny manifest database, for
+// debugging.
+//
+// Usage:
+//
+//	manifest --manifestdb=PATH get TABLE HASH
+//	manifest --manifestdb=PATH item HASH|NAME
+//	manifest --manifestdb=PATH vendor HASH|NAME
+//	manifest --manifestdb=PATH search TABLE TEXT
+//	manifest --manifestdb=PATH --userdb=DSN --tokenkeys=KEYS --apikey=KEY --authurl=URL --membershipid=ID sales VENDORHASH
+//
+// Hashes can be given either unsigned, as they are in the API, or signed, as
+// they are stored in the manifest database.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/zhirsch/oauth2"
+	"github.com/zhirsch/oauth2/bungie"
+
+	"github.com/zhirsch/destinykioskstatus/api"
+	"github.com/zhirsch/destinykioskstatus/db"
+)
+
+var (
+	manifestDBPath = flag.String("manifestdb", "", "The path to the manifest sqlite database.")
+	rawJSON        = flag.Bool("json", false, "Print the raw JSON of the definitions.")
+
+	// These are only needed for the sales command.
+	userDSN       = flag.String("userdb", "", "The user database: the path of a SQLite database, or a postgres:// URL.")
+	apiKey        = flag.String("apikey", "", "The Bungie API key.")
+	authURL       = flag.String("authurl", "", "The Bungie auth URL.")
+	membershipID  = flag.String("membershipid", "", "The Bungie membership ID of the user whose token is used.")
+	tokenKeys     = flag.String("tokenkeys", "", "Comma-separated id=key pairs of the keys that encrypt OAuth tokens in the user database.  Overrides --tokenkeysfile and $"+db.TokenKeysEnv+".")
+	tokenKeysFile = flag.String("tokenkeysfile", "", "The path to a file containing --tokenkeys.")
+)
+
+const (
+	tableVendor = "DestinyVendorDefinition"
+	tableItem   = "DestinyInventoryItemDefinition"
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %v [flags] get|item|vendor|search|sales ARGS...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if *manifestDBPath == "" {
+		log.Fatal("need to provide --manifestdb")
+	}
+	args := flag.Args()
+	if len(args) < 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	manifest, err := api.NewManifestWithOptions(*manifestDBPath, api.ManifestOptions{CacheSize: -1})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer manifest.Close()
+
+	switch cmd := args[0]; {
+	case cmd == "get" && len(args) == 3:
+		printRaw(mustGetRaw(manifest, args[1], mustParseHash(args[2])))
+	case cmd == "item" && len(args) == 2:
+		lookup(manifest, tableItem, args[1], itemName, printItem)
+	case cmd == "vendor" && len(args) == 2:
+		lookup(manifest, tableVendor, args[1], vendorName, printVendor)
+	case cmd == "search" && len(args) == 3:
+		definitions, err := manifest.SearchRawDefinitions(args[1], args[2])
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, definition := range definitions {
+			fmt.Printf("%v (id %v)\n", definition.Hash, api.HashToID(definition.Hash))
+			if *rawJSON {
+				printRaw(definition)
+			}
+		}
+	case cmd == "sales" && len(args) == 2:
+		sales(manifest, mustParseHash(args[1]))
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+// parseHash parses either an unsigned hash or a signed id.
+func parseHash(s string) (uint32, error) {
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if v < 0 {
+		if v < -1<<31 {
+			return 0, fmt.Errorf("%v is out of range", s)
+		}
+		return api.IDToHash(int32(v)), nil
+	}
+	if v > 1<<32-1 {
+		return 0, fmt.Errorf("%v is out of range", s)
+	}
+	return uint32(v), nil
+}
+
+func mustParseHash(s string) uint32 {
+	hash, err := parseHash(s)
+	if err != nil {
+		log.Fatalf("bad hash: %v", err)
+	}
+	return hash
+}
+
+func mustGetRaw(manifest *api.Manifest, table string, hash uint32) *api.RawDefinition {
+	definition, err := manifest.GetRawDefinition(table, hash)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return definition
+}
+
+// lookup prints the definition with the hash, or the definitions whose names
+// contain the argument (ignoring case) if it isn't a hash.
+func lookup(manifest *api.Manifest, table, arg string, name func(*api.RawDefinition) string, print func(*api.RawDefinition)) {
+	if hash, err := parseHash(arg); err == nil {
+		print(mustGetRaw(manifest, table, hash))
+		return
+	}
+	definitions, err := manifest.SearchRawDefinitions(table, arg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, definition := range definitions {
+		if strings.Contains(strings.ToLower(name(definition)), strings.ToLower(arg)) {
+			print(definition)
+		}
+	}
+}
+
+func itemName(raw *api.RawDefinition) string {
+	var definition api.DestinyInventoryItemDefinition
+	if err := json.Unmarshal(raw.JSON, &definition); err != nil {
+		log.Fatal(err)
+	}
+	return definition.ItemName
+}
+
+func vendorName(raw *api.RawDefinition) string {
+	var definition api.DestinyVendorDefinition
+	if err := json.Unmarshal(raw.JSON, &definition); err != nil {
+		log.Fatal(err)
+	}
+	return definition.Summary.VendorName
+}
+
+func printRaw(definition *api.RawDefinition) {
+	buf := new(bytes.Buffer)
+	if err := json.Indent(buf, definition.JSON, "", "  "); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(buf.String())
+}
+
+func printItem(raw *api.RawDefinition) {
+	var definition api.DestinyInventoryItemDefinition
+	if err := json.Unmarshal(raw.JSON, &definition); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%v (id %v): %v [%v %v]\n", raw.Hash, api.HashToID(raw.Hash), definition.ItemName, definition.TierTypeName, definition.ItemTypeName)
+	if *rawJSON {
+		printRaw(raw)
+	}
+}
+
+func printVendor(raw *api.RawDefinition) {
+	var definition api.DestinyVendorDefinition
+	if err := json.Unmarshal(raw.JSON, &definition); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%v (id %v): %v (%v)\n", raw.Hash, api.HashToID(raw.Hash), definition.Summary.VendorName, definition.Summary.VendorIdentifier)
+	for i, failureString := range definition.FailureStrings {
+		fmt.Printf("  failure %v: %q\n", i, failureString)
+	}
+	if *rawJSON {
+		printRaw(raw)
+	}
+}
+
+// sales prints the items that the vendor sells, as seen by the user's first
+// character, with their unlock flags.
+func sales(manifest *api.Manifest, vendorHash uint32) {
+	if *userDSN == "" || *apiKey == "" || *authURL == "" || *membershipID == "" {
+		log.Fatal("need to provide --userdb, --apikey, --authurl and --membershipid")
+	}
+	keyring, err := db.LoadKeyring(*tokenKeys, *tokenKeysFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	userDB, err := db.Open(*userDSN, keyring)
+	if err != nil {
+		log.Fatal(err)
+	}
+	bungieUser, err := userDB.SelectBungieUser(db.BungieMembershipID(*membershipID))
+	if err != nil {
+		log.Fatal(err)
+	}
+	destinyUser := bungieUser.DestinyUsers[0]
+	characterID := destinyUser.DestinyCharacters[0].CharacterID
+
+	client := &api.Client{AuthConfig: &oauth2.Config{
+		ClientID:  *apiKey,
+		Endpoint:  bungie.Endpoint(*authURL),
+		Exchanger: bungie.Exchanger{},
+	}}
+	vendorResp := client.MyCharacterVendorData(bungieUser.Token, destinyUser.MembershipType, characterID, vendorHash, "")
+
+	vendorDefinition, err := manifest.GetDestinyVendorDefinition(vendorHash)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%v (%v)\n", vendorDefinition.Summary.VendorName, vendorDefinition.Summary.VendorIdentifier)
+	for _, category := range vendorResp.Response.Data.SaleItemCategories {
+		fmt.Printf("%v\n", category.CategoryTitle)
+		for _, saleItem := range category.SaleItems {
+			name := "(not in manifest)"
+			if itemDefinition, err := manifest.GetDestinyInventoryItemDefinition(saleItem.Item.ItemHash); err == nil {
+				name = itemDefinition.ItemName
+			} else if !api.IsNotFound(err) {
+				log.Fatal(err)
+			}
+
+			var flags []string
+			for _, unlockStatus := range saleItem.UnlockStatuses {
+				flags = append(flags, fmt.Sprintf("%v=%v", unlockStatus.UnlockFlagHash, unlockStatus.IsSet))
+			}
+			fmt.Printf("  %v (id %v): %v\n", saleItem.Item.ItemHash, api.HashToID(saleItem.Item.ItemHash), name)
+			fmt.Printf("    unlock flags: %v\n", strings.Join(flags, " "))
+			if len(saleItem.FailureIndexes) > 0 {
+				fmt.Printf("    failure indexes: %v\n", saleItem.FailureIndexes)
+			}
+		}
+	}
+}
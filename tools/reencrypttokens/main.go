@@ -0,0 +1,39 @@
+// Command reencrypttokens encrypts all the OAuth tokens in the user database
+// with the primary token key.  Run it after adding a new primary key (or to
+// encrypt tokens that were stored before encryption), then remove the old
+// keys.
+package main
+
+import (
+	"flag"
+	"log"
+
+	"github.com/zhirsch/destinykioskstatus/db"
+)
+
+var (
+	userDSN       = flag.String("userdb", "", "The user database: the path of a SQLite database, or a postgres:// URL.")
+	tokenKeys     = flag.String("tokenkeys", "", "Comma-separated id=key pairs of the token keys.  The first key is the new primary key; the rest must include every key that tokens are currently encrypted with.  Overrides --tokenkeysfile and $"+db.TokenKeysEnv+".")
+	tokenKeysFile = flag.String("tokenkeysfile", "", "The path to a file containing --tokenkeys.")
+)
+
+func main() {
+	flag.Parse()
+	if *userDSN == "" {
+		log.Fatal("need to provide --userdb")
+	}
+
+	keyring, err := db.LoadKeyring(*tokenKeys, *tokenKeysFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	userDB, err := db.Open(*userDSN, keyring)
+	if err != nil {
+		log.Fatal(err)
+	}
+	n, err := userDB.ReencryptTokens()
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("re-encrypted the tokens of %v users", n)
+}
@@ -0,0 +1,39 @@
+// Command updatemanifest downloads the latest Destiny manifest database and
+// points a symlink at it, for use with --manifestdb.
+package main
+
+import (
+	"flag"
+	"log"
+
+	"github.com/zhirsch/oauth2"
+
+	"github.com/zhirsch/destinykioskstatus/api"
+)
+
+var (
+	apiKey  = flag.String("apikey", "", "The Bungie API key.")
+	locale  = flag.String("locale", "en", "The locale of the manifest to download.")
+	link    = flag.String("link", "", "The path of the symlink to point at the downloaded manifest.")
+	baseURL = flag.String("baseurl", "", "Overrides the Bungie base URL.")
+)
+
+func main() {
+	flag.Parse()
+	if *apiKey == "" {
+		log.Fatal("need to provide --apikey")
+	}
+	if *link == "" {
+		log.Fatal("need to provide --link")
+	}
+
+	client := &api.Client{
+		AuthConfig: &oauth2.Config{ClientID: *apiKey},
+		BaseURL:    *baseURL,
+	}
+	path, err := client.UpdateManifest(*locale, *link)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("%v now points to %v", *link, path)
+}